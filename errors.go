@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by every backend, wrapped together with the backend
+// specific cause. Check them with errors.Is.
+var (
+	ErrNotFound      = errors.New("file not found")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrForbidden     = errors.New("forbidden")
+	ErrConflict      = errors.New("conflict")
+	ErrTooLarge      = errors.New("file too large")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrInvalidFileName is returned for file names which can't be
+	// sanitized, such as names containing a path.
+	ErrInvalidFileName = errors.New("invalid file name")
+)
+
+// ErrorFromStatus returns the error matching an HTTP status code, or nil
+// when there is none.
+func ErrorFromStatus(statusCode int) error {
+	switch statusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return ErrConflict
+	case http.StatusRequestEntityTooLarge:
+		return ErrTooLarge
+	case http.StatusInsufficientStorage:
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// StatusError is returned by HTTP based backends when the server responds
+// with an error. Use errors.As to get the status code and message.
+type StatusError struct {
+	StatusCode int
+	Message    string
+
+	// Err is the error matching the response. It defaults to
+	// ErrorFromStatus(StatusCode) when nil.
+	Err error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+
+	return ErrorFromStatus(e.StatusCode)
+}
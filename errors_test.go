@@ -0,0 +1,40 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusNotFound, storage.ErrNotFound},
+		{http.StatusUnauthorized, storage.ErrUnauthorized},
+		{http.StatusForbidden, storage.ErrForbidden},
+		{http.StatusConflict, storage.ErrConflict},
+		{http.StatusRequestEntityTooLarge, storage.ErrTooLarge},
+		{http.StatusInsufficientStorage, storage.ErrQuotaExceeded},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("failed to get file: %w", &storage.StatusError{StatusCode: tt.statusCode})
+		assert.ErrorIs(t, err, tt.want, "status %d", tt.statusCode)
+
+		var statusErr *storage.StatusError
+		if assert.ErrorAs(t, err, &statusErr) {
+			assert.Equal(t, tt.statusCode, statusErr.StatusCode)
+		}
+	}
+
+	err := &storage.StatusError{StatusCode: http.StatusInternalServerError}
+	assert.Nil(t, errors.Unwrap(err))
+
+	err = &storage.StatusError{StatusCode: http.StatusOK, Err: storage.ErrQuotaExceeded}
+	assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+}
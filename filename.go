@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultMaxFileNameLength leaves room for the extension and suffixes
+// within the 255 bytes most file systems allow.
+const DefaultMaxFileNameLength = 200
+
+// FileNamePolicy sanitizes file names, without the extension, before they
+// are stored. Names containing a path are always rejected with
+// ErrInvalidFileName. The zero value accepts any other name as it is; use
+// DefaultFileNamePolicy for a strict policy.
+type FileNamePolicy struct {
+	// Disallowed matches the characters which are replaced with
+	// Replacement. Nothing is replaced when nil.
+	Disallowed *regexp.Regexp
+
+	// Replacement replaces every match of Disallowed. Empty removes them.
+	Replacement string
+
+	// Transliterate converts letters with diacritics and ligatures to
+	// ASCII, e.g. "Café Straße" to "Cafe Strasse", before Disallowed is
+	// applied.
+	Transliterate bool
+
+	// MaxLength is the maximum number of characters of the name. Longer
+	// names are cut. There is no limit when zero.
+	MaxLength int
+
+	// ReservedNames can't be used as a name, compared case-insensitively.
+	// They are prefixed with an underscore.
+	ReservedNames []string
+
+	// Fallback returns the name of files whose name ends up empty. Defaults
+	// to "undefined_" followed by the current Unix time.
+	Fallback func() string
+}
+
+var windowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// DefaultFileNamePolicy returns a policy which transliterates names to
+// ASCII, replaces anything but letters, digits, dashes and underscores with
+// an underscore, cuts names to DefaultMaxFileNameLength and avoids the
+// names Windows reserves for devices.
+func DefaultFileNamePolicy() *FileNamePolicy {
+	return &FileNamePolicy{
+		Disallowed:    regexp.MustCompile(`[^a-zA-Z0-9_-]+`),
+		Replacement:   "_",
+		Transliterate: true,
+		MaxLength:     DefaultMaxFileNameLength,
+		ReservedNames: windowsReservedNames,
+	}
+}
+
+// Sanitize returns name made safe according to p. Names which end up empty
+// are replaced with the name returned by p.Fallback.
+func (p FileNamePolicy) Sanitize(name string) (string, error) {
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return "", fmt.Errorf("%w: %q contains a path", ErrInvalidFileName, name)
+	}
+
+	// Leading dots hide files and trailing ones are dropped by Windows.
+	name = strings.Trim(name, " .")
+	if p.Transliterate {
+		name = transliterate(name)
+	}
+	if p.Disallowed != nil {
+		name = strings.Trim(p.Disallowed.ReplaceAllLiteralString(name, p.Replacement), " .")
+	}
+	if p.MaxLength > 0 && utf8.RuneCountInString(name) > p.MaxLength {
+		name = strings.TrimRight(string([]rune(name)[:p.MaxLength]), " .")
+	}
+	for _, reserved := range p.ReservedNames {
+		if strings.EqualFold(name, reserved) {
+			name = "_" + name
+			break
+		}
+	}
+	if name == "" && p.Fallback != nil {
+		name = p.Fallback()
+	}
+	if name == "" {
+		name = fmt.Sprintf("undefined_%d", time.Now().Unix())
+	}
+
+	return name, nil
+}
+
+// ligatures are the letters which have no decomposition into an ASCII
+// letter and a diacritic.
+var ligatures = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE",
+	"ø", "o", "Ø", "O", "đ", "d", "Đ", "D", "ð", "d", "Ð", "D",
+	"ł", "l", "Ł", "L", "þ", "th", "Þ", "Th", "ı", "i",
+)
+
+func transliterate(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, name)
+	if err != nil {
+		return ligatures.Replace(name)
+	}
+
+	return ligatures.Replace(result)
+}
@@ -0,0 +1,65 @@
+package storage_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultFileNamePolicy(t *testing.T) {
+	policy := storage.DefaultFileNamePolicy()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report-2024_final", "report-2024_final"},
+		{"my report (1)", "my_report_1_"},
+		{"Café Straße", "Cafe_Strasse"},
+		{"Łódź", "Lodz"},
+		{".hidden", "hidden"},
+		{"con", "_con"},
+		{"LPT1", "_LPT1"},
+		{strings.Repeat("a", 300), strings.Repeat("a", storage.DefaultMaxFileNameLength)},
+	}
+	for _, tt := range tests {
+		got, err := policy.Sanitize(tt.name)
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+
+	got, err := policy.Sanitize("...")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, "undefined_"), got)
+}
+
+func TestFileNamePolicyRejectsPaths(t *testing.T) {
+	var policy storage.FileNamePolicy
+	for _, name := range []string{"..", ".", "../secret", "a/b", `C:\Users\file`, "a\x00b"} {
+		_, err := policy.Sanitize(name)
+		assert.ErrorIs(t, err, storage.ErrInvalidFileName, name)
+	}
+
+	got, err := policy.Sanitize("laporan akhir (revisi)")
+	assert.NoError(t, err)
+	assert.Equal(t, "laporan akhir (revisi)", got)
+}
+
+func TestFileNamePolicyRemovesDisallowed(t *testing.T) {
+	policy := storage.FileNamePolicy{Disallowed: regexp.MustCompile(`[^a-zA-Z0-9]+`)}
+	got, err := policy.Sanitize("sample-file 01")
+	assert.NoError(t, err)
+	assert.Equal(t, "samplefile01", got)
+}
+
+func TestFileNamePolicyFallback(t *testing.T) {
+	policy := storage.FileNamePolicy{
+		Disallowed: regexp.MustCompile(`[^a-zA-Z0-9]+`),
+		Fallback:   func() string { return "untitled" },
+	}
+	got, err := policy.Sanitize("日本")
+	assert.NoError(t, err)
+	assert.Equal(t, "untitled", got)
+}
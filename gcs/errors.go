@@ -0,0 +1,27 @@
+package gcs
+
+import (
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	gostorage "github.com/dptsi/go-storage"
+	"google.golang.org/api/googleapi"
+)
+
+// wrapError adds the gostorage error matching the GCS error to err, so
+// callers can use errors.Is(err, gostorage.ErrNotFound).
+func wrapError(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %w", gostorage.ErrNotFound, err)
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		if target := gostorage.ErrorFromStatus(apiErr.Code); target != nil {
+			return fmt.Errorf("%w: %w", target, err)
+		}
+	}
+
+	return err
+}
@@ -1,60 +1,337 @@
 package gcs
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
+	gostorage "github.com/dptsi/go-storage"
 	"github.com/google/uuid"
+	"google.golang.org/api/option"
 )
 
 type Config struct {
 	Bucket string
+
+	// CredentialsJSON is the content of a service account key file. It takes
+	// precedence over CredentialsFile. Application default credentials are
+	// used when both are empty.
+	CredentialsJSON []byte
+
+	// CredentialsFile is the path of a service account key file.
+	CredentialsFile string
+
+	// Endpoint overrides the JSON API endpoint, e.g.
+	// "https://storage.example.com/storage/v1/".
+	Endpoint string
+
+	// EmulatorHost is the address of a GCS emulator such as
+	// fake-gcs-server, e.g. "localhost:4443" or "http://localhost:4443".
+	// Requests to the emulator aren't authenticated. The client also honours
+	// the STORAGE_EMULATOR_HOST environment variable.
+	EmulatorHost string
+
+	// ClientOptions are passed to storage.NewClient after the options above.
+	ClientOptions []option.ClientOption
+
+	// FileNamePolicy sanitizes the names of uploaded files. Defaults to
+	// gostorage.DefaultFileNamePolicy().
+	FileNamePolicy *gostorage.FileNamePolicy
 }
 
 type GCS struct {
-	bucket string
-	client *storage.Client
+	bucket         string
+	client         *storage.Client
+	fileNamePolicy *gostorage.FileNamePolicy
+
+	// signer is set when a service account key is configured, so links can
+	// be signed even when the client isn't authenticated.
+	signer *signer
+	// insecure makes signed URLs use HTTP, for emulators.
+	insecure bool
+}
+
+type signer struct {
+	googleAccessID string
+	privateKey     []byte
 }
 
 func NewGCS(ctx context.Context, cfg Config) (*GCS, error) {
-	s, err := storage.NewClient(ctx)
+	credentialsJSON := cfg.CredentialsJSON
+	if len(credentialsJSON) == 0 && cfg.CredentialsFile != "" {
+		b, err := os.ReadFile(cfg.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		credentialsJSON = b
+	}
+	var sign *signer
+	if len(credentialsJSON) > 0 {
+		s, err := parseSigner(credentialsJSON)
+		if err != nil {
+			return nil, err
+		}
+		sign = s
+	}
+
+	var opts []option.ClientOption
+	endpoint := cfg.Endpoint
+	if cfg.EmulatorHost != "" {
+		endpoint = emulatorEndpoint(cfg.EmulatorHost)
+		// Emulators serve the XML API only on their public host, so reads
+		// go through the JSON API.
+		opts = append(opts, option.WithoutAuthentication(), storage.WithJSONReads())
+	} else if len(credentialsJSON) > 0 {
+		opts = append(opts, option.WithCredentialsJSON(credentialsJSON))
+	}
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	opts = append(opts, cfg.ClientOptions...)
+
+	s, err := storage.NewClient(ctx, opts...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create gcs client: %w", err)
+	}
+	fileNamePolicy := cfg.FileNamePolicy
+	if fileNamePolicy == nil {
+		fileNamePolicy = gostorage.DefaultFileNamePolicy()
 	}
+
 	return &GCS{
-		bucket: cfg.Bucket,
-		client: s,
+		bucket:         cfg.Bucket,
+		client:         s,
+		fileNamePolicy: fileNamePolicy,
+		signer:         sign,
+		insecure:       strings.HasPrefix(endpoint, "http://"),
 	}, nil
 }
 
+// Upload stores file under a new file ID, with its content type detected
+// from the content. The file has no name; use UploadWithName to keep one.
 func (s *GCS) Upload(ctx context.Context, file io.Reader) (FileInfo, error) {
+	return s.put(ctx, file, "", "", gostorage.UploadOptions{})
+}
+
+// UploadWithName stores file under a new file ID, with its content type
+// detected from the content and name and ext kept in the object metadata.
+func (s *GCS) UploadWithName(ctx context.Context, file io.Reader, name, ext string) (FileInfo, error) {
+	return s.UploadWithOptions(ctx, file, name, ext, gostorage.UploadOptions{})
+}
+
+// UploadWithOptions is UploadWithName with control over the headers,
+// metadata and storage class of the object.
+func (s *GCS) UploadWithOptions(ctx context.Context, file io.Reader, name, ext string, opts gostorage.UploadOptions) (FileInfo, error) {
+	name, err := s.CleanFileName(name)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to sanitize file name: %w", err)
+	}
+
+	return s.put(ctx, file, name, ext, opts)
+}
+
+// put uploads file as a new object. Files without a name and ext get no
+// Content-Disposition and no name metadata.
+func (s *GCS) put(ctx context.Context, file io.Reader, name, ext string, opts gostorage.UploadOptions) (FileInfo, error) {
+	var err error
+	buffered := bufio.NewReader(file)
+	mime := opts.ContentType
+	if mime == "" {
+		mime, err = s.detectMimeType(buffered)
+		if err != nil {
+			return FileInfo{}, fmt.Errorf("failed to detect mime type: %w", err)
+		}
+	}
+
+	// Cancelling the context of the writer aborts the upload, so nothing is
+	// left behind when copying fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	fileId := uuid.NewString()
-	object := s.client.Bucket(s.bucket).Object(fileId)
-	w := object.NewWriter(ctx)
+	w := s.client.Bucket(s.bucket).Object(fileId).NewWriter(ctx)
+	w.ContentType = mime
+	w.ContentDisposition = opts.Disposition(name, ext)
+	w.CacheControl = opts.CacheControl
+	w.ContentEncoding = opts.ContentEncoding
+	w.Metadata = gostorage.ObjectMetadata(name, ext, opts.Metadata)
+	w.StorageClass = opts.StorageClass
+	w.PredefinedACL = opts.ACL
 
-	if _, err := io.Copy(w, file); err != nil {
-		return FileInfo{}, fmt.Errorf("failed to put object to GCS: %w", err)
+	if _, err := io.Copy(w, buffered); err != nil {
+		return FileInfo{}, fmt.Errorf("failed to put object to GCS: %w", wrapError(err))
 	}
 	if err := w.Close(); err != nil {
-		return FileInfo{}, fmt.Errorf("failed to close writer: %w", err)
+		return FileInfo{}, fmt.Errorf("failed to close writer: %w", wrapError(err))
 	}
 	attrs := w.Attrs()
 
 	return FileInfo{
-		FileID:    fileId,
-		FileSize:  int(attrs.Size),
-		ETag:      attrs.Etag,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		FileID:       fileId,
+		FileName:     name,
+		FileExt:      ext,
+		FileMimetype: mime,
+		FileSize:     int(attrs.Size),
+		ETag:         attrs.Etag,
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Metadata:     gostorage.CustomMetadata(attrs.Metadata),
+	}, nil
+}
+
+func (s *GCS) UploadFromBase64(ctx context.Context, base64String, name, ext string) (FileInfo, error) {
+	data, err := base64.StdEncoding.DecodeString(base64String)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to decode base64 string: %w", err)
+	}
+
+	return s.UploadWithName(ctx, bytes.NewReader(data), name, ext)
+}
+
+func (s *GCS) Download(ctx context.Context, fileId, path string) (*os.File, error) {
+	r, err := s.client.Bucket(s.bucket).Object(fileId).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from GCS: %w", wrapError(err))
+	}
+	defer r.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file to path %s: %w", path, err)
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		return nil, fmt.Errorf("failed to copy file to path %s: %w", path, wrapError(err))
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	return file, nil
+}
+
+func (s *GCS) DownloadAsBase64(ctx context.Context, fileId string) (string, error) {
+	r, err := s.client.Bucket(s.bucket).Object(fileId).NewReader(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get object from GCS: %w", wrapError(err))
+	}
+	defer r.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, r); err != nil {
+		return "", fmt.Errorf("failed to copy file to buffer: %w", wrapError(err))
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (s *GCS) FileInfo(ctx context.Context, fileId string) (FileInfo, error) {
+	attrs, err := s.client.Bucket(s.bucket).Object(fileId).Attrs(ctx)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to get object attrs from GCS: %w", wrapError(err))
+	}
+
+	return fileInfo(attrs), nil
+}
+
+// PublicLink returns a V4 signed URL. Signing needs credentials with a
+// private key, or the IAM signBlob permission for the service account.
+func (s *GCS) PublicLink(
+	ctx context.Context,
+	fileId string,
+	publicLinkExpiration time.Duration,
+) (PublicLinkResponse, error) {
+	if publicLinkExpiration <= 0 {
+		publicLinkExpiration = gostorage.DefaultPublicLinkExpiration
+	}
+	expiredAt := time.Now().Add(publicLinkExpiration)
+	opts := s.signedURLOptions(http.MethodGet, expiredAt)
+	url, err := s.client.Bucket(s.bucket).SignedURL(fileId, opts)
+	if err != nil {
+		return PublicLinkResponse{}, fmt.Errorf("failed to sign url from GCS: %w", err)
+	}
+
+	return PublicLinkResponse{
+		Url:       url,
+		ExpiredAt: expiredAt.UTC().Format(time.RFC3339),
 	}, nil
 }
 
 func (s *GCS) Delete(ctx context.Context, fileId string) error {
-	return s.client.Bucket(s.bucket).Object(fileId).Delete(ctx)
+	if err := s.client.Bucket(s.bucket).Object(fileId).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete object from GCS: %w", wrapError(err))
+	}
+
+	return nil
 }
 
 func (s *GCS) Stream(ctx context.Context, fileId string) (io.ReadCloser, error) {
-	return s.client.Bucket(s.bucket).Object(fileId).NewReader(ctx)
+	r, err := s.client.Bucket(s.bucket).Object(fileId).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from GCS: %w", wrapError(err))
+	}
+
+	return r, nil
+}
+
+// StreamRange implements gostorage.RangeReader.
+func (s *GCS) StreamRange(ctx context.Context, fileId string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		// GCS would read from the end of the file instead.
+		return nil, fmt.Errorf("invalid range offset %d", offset)
+	}
+	if length < 0 {
+		length = -1
+	}
+	r, err := s.client.Bucket(s.bucket).Object(fileId).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object range from GCS: %w", wrapError(err))
+	}
+
+	return r, nil
+}
+
+// Open returns a gostorage.ObjectReader of the file.
+func (s *GCS) Open(ctx context.Context, fileId string) (*gostorage.ObjectReader, error) {
+	info, err := s.FileInfo(ctx, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	return gostorage.NewObjectReader(ctx, s, fileId, int64(info.FileSize)), nil
+}
+
+// CleanFileName applies Config.FileNamePolicy to nameWithoutExt. Names
+// containing a path return gostorage.ErrInvalidFileName.
+func (s *GCS) CleanFileName(nameWithoutExt string) (string, error) {
+	return s.fileNamePolicy.Sanitize(nameWithoutExt)
+}
+
+func (s *GCS) GetFileExt(nameWithExt string) string {
+	return filepath.Ext(nameWithExt)
+}
+
+// signedURLOptions returns the options to sign a URL with the configured
+// service account key, or with the credentials of the client otherwise.
+func (s *GCS) signedURLOptions(method string, expires time.Time) *storage.SignedURLOptions {
+	opts := &storage.SignedURLOptions{
+		Scheme:   storage.SigningSchemeV4,
+		Method:   method,
+		Expires:  expires,
+		Insecure: s.insecure,
+	}
+	if s.signer != nil {
+		opts.GoogleAccessID = s.signer.googleAccessID
+		opts.PrivateKey = s.signer.privateKey
+	}
+
+	return opts
 }
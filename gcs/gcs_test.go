@@ -0,0 +1,419 @@
+package gcs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/dptsi/go-storage/gcs"
+	"github.com/dptsi/go-storage/storagetest"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/stretchr/testify/assert"
+)
+
+const testBucket = "test-bucket"
+
+var sampleFile = append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("sample file content\n"), 64)...)
+
+// newEmulator starts an in-process fake-gcs-server with an empty test
+// bucket and returns its URL.
+func newEmulator(t *testing.T) string {
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		Scheme: "http",
+		Host:   "127.0.0.1",
+		// Signed URLs address the emulator by its IP.
+		PublicHost: "127.0.0.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	server.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: testBucket})
+
+	return server.URL()
+}
+
+// serviceAccountKey returns a service account key file with a new private
+// key, so links can be signed without real credentials.
+func serviceAccountKey(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	b, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "test@example.iam.gserviceaccount.com",
+		"private_key":  string(privateKey),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func getGCS(t *testing.T) *gcs.GCS {
+	s, err := gcs.NewGCS(context.Background(), gcs.Config{
+		Bucket:          testBucket,
+		CredentialsJSON: serviceAccountKey(t),
+		EmulatorHost:    newEmulator(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestUploadFile(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	info, err := s.UploadWithName(ctx, bytes.NewReader(sampleFile), "sample file", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, info.FileID)
+	assert.Equal(t, "sample_file", info.FileName)
+	assert.Equal(t, ".pdf", info.FileExt)
+	assert.Equal(t, "application/pdf", info.FileMimetype)
+	assert.Equal(t, len(sampleFile), info.FileSize)
+	assert.NotEmpty(t, info.Timestamp)
+
+	got, err := s.FileInfo(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, info.FileName, got.FileName)
+	assert.Equal(t, info.FileExt, got.FileExt)
+	assert.Equal(t, info.FileMimetype, got.FileMimetype)
+	assert.Equal(t, info.FileSize, got.FileSize)
+}
+
+func TestUploadWithoutName(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	info, err := s.Upload(ctx, bytes.NewReader(sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "application/pdf", info.FileMimetype)
+
+	got, err := s.FileInfo(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, got.FileName)
+	assert.Empty(t, got.FileExt)
+	assert.Equal(t, len(sampleFile), got.FileSize)
+}
+
+func TestUploadWithOptions(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	info, err := s.UploadWithOptions(ctx, bytes.NewReader(sampleFile), "sample", ".pdf", storage.UploadOptions{
+		ContentType:  "application/x-custom",
+		CacheControl: "public, max-age=3600",
+		Metadata:     map[string]string{"owner": "Dürer", "name": "ignored"},
+		StorageClass: "NEARLINE",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "application/x-custom", info.FileMimetype)
+	assert.Equal(t, map[string]string{"owner": "Dürer"}, info.Metadata)
+
+	got, err := s.FileInfo(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "sample", got.FileName)
+	assert.Equal(t, "application/x-custom", got.FileMimetype)
+	assert.Equal(t, map[string]string{"owner": "Dürer"}, got.Metadata)
+}
+
+func TestUploadAndDownloadBase64(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	encoded := base64.StdEncoding.EncodeToString(sampleFile)
+	info, err := s.UploadFromBase64(ctx, encoded, "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.DownloadAsBase64(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, encoded, got)
+}
+
+func TestDownloadFile(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	info, err := s.UploadWithName(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := s.Download(ctx, info.FileID, filepath.Join(t.TempDir(), "sample.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sampleFile, got)
+}
+
+func TestStreamRange(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	info, err := s.UploadWithName(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.StreamRange(ctx, info.FileID, 100, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sampleFile[100:150], got)
+
+	o, err := s.Open(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if _, err := o.Seek(-16, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	got, err = io.ReadAll(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sampleFile[len(sampleFile)-16:], got)
+}
+
+func TestPublicLink(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	info, err := s.UploadWithName(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := s.PublicLink(ctx, info.FileID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "http", u.Scheme)
+	assert.Equal(t, "/"+testBucket+"/"+info.FileID, u.Path)
+	assert.True(t, strings.HasPrefix(u.Query().Get("X-Goog-Credential"), "test@example.iam.gserviceaccount.com/"))
+	assert.NotEmpty(t, u.Query().Get("X-Goog-Signature"))
+}
+
+func TestPresignUpload(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	upload, err := s.PresignUpload(ctx, gcs.PresignUploadOptions{
+		Name:        "report",
+		Ext:         ".pdf",
+		ContentType: "application/pdf",
+		MaxSize:     1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.MethodPut, upload.Method)
+	assert.Equal(t, "0,1048576", upload.Headers["X-Goog-Content-Length-Range"])
+
+	req, err := http.NewRequest(upload.Method, upload.Url, bytes.NewReader(sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range upload.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	info, err := s.FileInfo(ctx, upload.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "report", info.FileName)
+	assert.Equal(t, ".pdf", info.FileExt)
+	assert.Equal(t, "application/pdf", info.FileMimetype)
+	assert.Equal(t, len(sampleFile), info.FileSize)
+}
+
+func TestPresignUploadPost(t *testing.T) {
+	ctx := context.Background()
+	s := getGCS(t)
+
+	upload, err := s.PresignUploadPost(ctx, gcs.PresignUploadOptions{
+		Name:        "report",
+		Ext:         ".pdf",
+		ContentType: "application/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.MethodPost, upload.Method)
+	assert.Equal(t, upload.FileID, upload.Fields["key"])
+	assert.NotEmpty(t, upload.Fields["x-goog-signature"])
+
+	// Fields go before the file, which must be the last field.
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	for key, value := range upload.Fields {
+		if err := w.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteField("Content-Type", "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	part, err := w.CreateFormFile("file", "report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(sampleFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(upload.Method, strings.TrimSuffix(upload.Url, "/"), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	// The emulator only accepts forms on its public host without a port.
+	req.Host = "127.0.0.1"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Less(t, resp.StatusCode, 300)
+
+	info, err := s.FileInfo(ctx, upload.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "report", info.FileName)
+	assert.Equal(t, ".pdf", info.FileExt)
+	assert.Equal(t, len(sampleFile), info.FileSize)
+}
+
+func TestNewGCSWithCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, serviceAccountKey(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := gcs.NewGCS(context.Background(), gcs.Config{
+		Bucket:          testBucket,
+		CredentialsFile: path,
+		EmulatorHost:    newEmulator(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.PublicLink(context.Background(), "file", time.Minute)
+	assert.NoError(t, err)
+
+	_, err = gcs.NewGCS(context.Background(), gcs.Config{
+		CredentialsFile: filepath.Join(t.TempDir(), "missing.json"),
+	})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestServeFileRequestsRanges(t *testing.T) {
+	ctx := context.Background()
+	emulator, err := url.Parse(newEmulator(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Record the Range header of every download.
+	var ranges []string
+	proxy := httputil.NewSingleHostReverseProxy(emulator)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	s, err := gcs.NewGCS(ctx, gcs.Config{
+		Bucket:          testBucket,
+		CredentialsJSON: serviceAccountKey(t),
+		EmulatorHost:    server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.UploadWithName(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+info.FileID, nil)
+	req.Header.Set("Range", "bytes=10-13")
+	rec := httptest.NewRecorder()
+	storage.ServeFile(rec, req, s.Storage(), info.FileID)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, sampleFile[10:14], rec.Body.Bytes())
+	assert.Equal(t, []string{"bytes=10-13"}, ranges)
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return getGCS(t).Storage()
+	})
+}
@@ -0,0 +1,46 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	gostorage "github.com/dptsi/go-storage"
+	"google.golang.org/api/iterator"
+)
+
+const defaultPageSize = 1000
+
+// List returns a page of the files in the bucket.
+func (s *GCS) List(ctx context.Context, opts gostorage.ListOptions) (gostorage.ListResult, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+	query := &storage.Query{
+		Prefix:    opts.Prefix,
+		Delimiter: opts.Delimiter,
+	}
+	it := s.client.Bucket(s.bucket).Objects(ctx, query)
+
+	var objects []*storage.ObjectAttrs
+	next, err := iterator.NewPager(it, opts.PageSize, opts.PageToken).NextPage(&objects)
+	if err != nil {
+		return gostorage.ListResult{}, fmt.Errorf("failed to list objects from GCS: %w", wrapError(err))
+	}
+
+	result := gostorage.ListResult{
+		Files:         make([]gostorage.FileInfo, 0, len(objects)),
+		NextPageToken: next,
+	}
+	for _, attrs := range objects {
+		// With a delimiter, prefixes are listed as objects with only
+		// Prefix set.
+		if attrs.Prefix != "" {
+			result.Prefixes = append(result.Prefixes, attrs.Prefix)
+			continue
+		}
+		result.Files = append(result.Files, fileInfo(attrs).toStorage())
+	}
+
+	return result, nil
+}
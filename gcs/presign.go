@@ -0,0 +1,151 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	gostorage "github.com/dptsi/go-storage"
+	"github.com/google/uuid"
+)
+
+type PresignUploadOptions struct {
+	// Name and Ext are stored as the file name and extension, like the
+	// arguments of Upload.
+	Name string
+	Ext  string
+
+	// ContentType is the content type the client must upload the file
+	// with. For POST uploads, a value ending with a slash, such as
+	// "image/", allows any content type with that prefix. Anything is
+	// allowed when empty.
+	ContentType string
+
+	// MinSize and MaxSize limit the size of the upload in bytes. MaxSize
+	// isn't limited when zero.
+	MinSize int64
+	MaxSize int64
+
+	// Expiration defaults to gostorage.DefaultPublicLinkExpiration.
+	Expiration time.Duration
+}
+
+func (o PresignUploadOptions) contentLengthRange() (minSize, maxSize int64, ok bool) {
+	if o.MinSize <= 0 && o.MaxSize <= 0 {
+		return 0, 0, false
+	}
+	maxSize = o.MaxSize
+	if maxSize <= 0 {
+		// The largest object GCS accepts.
+		maxSize = 5 << 40
+	}
+
+	return o.MinSize, maxSize, true
+}
+
+// PresignUpload returns a V4 signed URL the client can PUT the file to
+// directly, without sending it through this service. The file ID is
+// allocated up front; the file exists once the client finished uploading.
+// The client must send Headers with the request.
+func (s *GCS) PresignUpload(ctx context.Context, opts PresignUploadOptions) (PresignedUpload, error) {
+	name, err := s.CleanFileName(opts.Name)
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to sanitize file name: %w", err)
+	}
+	if opts.Expiration <= 0 {
+		opts.Expiration = gostorage.DefaultPublicLinkExpiration
+	}
+
+	headers := map[string]string{
+		"Content-Disposition": gostorage.AttachmentDisposition(name, opts.Ext),
+	}
+	for key, value := range gostorage.ObjectMetadata(name, opts.Ext, nil) {
+		headers[http.CanonicalHeaderKey("x-goog-meta-"+key)] = value
+	}
+	if minSize, maxSize, ok := opts.contentLengthRange(); ok {
+		headers["X-Goog-Content-Length-Range"] = fmt.Sprintf("%d,%d", minSize, maxSize)
+	}
+	signedHeaders := make([]string, 0, len(headers))
+	for key, value := range headers {
+		signedHeaders = append(signedHeaders, key+":"+value)
+	}
+	if opts.ContentType != "" {
+		headers["Content-Type"] = opts.ContentType
+	}
+
+	fileId := uuid.NewString()
+	expiredAt := time.Now().Add(opts.Expiration)
+	signOpts := s.signedURLOptions(http.MethodPut, expiredAt)
+	signOpts.ContentType = opts.ContentType
+	signOpts.Headers = signedHeaders
+	url, err := s.client.Bucket(s.bucket).SignedURL(fileId, signOpts)
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to sign url from GCS: %w", err)
+	}
+
+	return PresignedUpload{
+		FileID:    fileId,
+		Url:       url,
+		Method:    http.MethodPut,
+		Headers:   headers,
+		ExpiredAt: expiredAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// PresignUploadPost returns a V4 POST policy the client can upload the file
+// with from an HTML form. The client sends Fields as form fields followed by
+// the file in a field named "file".
+func (s *GCS) PresignUploadPost(ctx context.Context, opts PresignUploadOptions) (PresignedUpload, error) {
+	name, err := s.CleanFileName(opts.Name)
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to sanitize file name: %w", err)
+	}
+	if opts.Expiration <= 0 {
+		opts.Expiration = gostorage.DefaultPublicLinkExpiration
+	}
+
+	// Unlike object attrs, the policy takes metadata as form fields.
+	metadata := make(map[string]string)
+	for key, value := range gostorage.ObjectMetadata(name, opts.Ext, nil) {
+		metadata["x-goog-meta-"+key] = value
+	}
+	fields := &storage.PolicyV4Fields{
+		ContentDisposition: gostorage.AttachmentDisposition(name, opts.Ext),
+		Metadata:           metadata,
+	}
+	var conditions []storage.PostPolicyV4Condition
+	if strings.HasSuffix(opts.ContentType, "/") {
+		conditions = append(conditions, storage.ConditionStartsWith("$Content-Type", opts.ContentType))
+	} else {
+		fields.ContentType = opts.ContentType
+	}
+	if minSize, maxSize, ok := opts.contentLengthRange(); ok {
+		conditions = append(conditions, storage.ConditionContentLengthRange(uint64(minSize), uint64(maxSize)))
+	}
+
+	fileId := uuid.NewString()
+	expiredAt := time.Now().Add(opts.Expiration)
+	signOpts := s.signedURLOptions(http.MethodPost, expiredAt)
+	policy, err := s.client.Bucket(s.bucket).GenerateSignedPostPolicyV4(fileId, &storage.PostPolicyV4Options{
+		GoogleAccessID: signOpts.GoogleAccessID,
+		PrivateKey:     signOpts.PrivateKey,
+		Expires:        expiredAt,
+		Insecure:       s.insecure,
+		Fields:         fields,
+		Conditions:     conditions,
+	})
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to sign post policy from GCS: %w", err)
+	}
+
+	return PresignedUpload{
+		FileID:    fileId,
+		Url:       policy.URL,
+		Method:    http.MethodPost,
+		Fields:    policy.Fields,
+		ExpiredAt: expiredAt.UTC().Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	gostorage "github.com/dptsi/go-storage"
+)
+
+// Storage returns s as a backend-agnostic gostorage.Storage, which also
+// implements gostorage.RangeReader and gostorage.Lister.
+func (s *GCS) Storage() gostorage.Storage {
+	return storageAdapter{s: s}
+}
+
+var (
+	_ gostorage.RangeReader = storageAdapter{}
+	_ gostorage.Lister      = storageAdapter{}
+)
+
+type storageAdapter struct {
+	s *GCS
+}
+
+func (a storageAdapter) Upload(ctx context.Context, file io.Reader, name, ext string) (gostorage.FileInfo, error) {
+	info, err := a.s.UploadWithName(ctx, file, name, ext)
+	if err != nil {
+		return gostorage.FileInfo{}, err
+	}
+
+	return info.toStorage(), nil
+}
+
+func (a storageAdapter) Stream(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	return a.s.Stream(ctx, fileId)
+}
+
+func (a storageAdapter) StreamRange(ctx context.Context, fileId string, offset, length int64) (io.ReadCloser, error) {
+	return a.s.StreamRange(ctx, fileId, offset, length)
+}
+
+func (a storageAdapter) FileInfo(ctx context.Context, fileId string) (gostorage.FileInfo, error) {
+	info, err := a.s.FileInfo(ctx, fileId)
+	if err != nil {
+		return gostorage.FileInfo{}, err
+	}
+
+	return info.toStorage(), nil
+}
+
+func (a storageAdapter) PublicLink(
+	ctx context.Context,
+	fileId string,
+	expiration time.Duration,
+) (gostorage.PublicLinkResponse, error) {
+	link, err := a.s.PublicLink(ctx, fileId, expiration)
+	if err != nil {
+		return gostorage.PublicLinkResponse{}, err
+	}
+
+	return gostorage.PublicLinkResponse(link), nil
+}
+
+// List implements gostorage.Lister.
+func (a storageAdapter) List(ctx context.Context, opts gostorage.ListOptions) (gostorage.ListResult, error) {
+	return a.s.List(ctx, opts)
+}
+
+func (a storageAdapter) Delete(ctx context.Context, fileId string) error {
+	if err := a.s.Delete(ctx, fileId); err != nil && !errors.Is(err, gostorage.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (f FileInfo) toStorage() gostorage.FileInfo {
+	return gostorage.FileInfo(f)
+}
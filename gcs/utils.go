@@ -1,25 +1,67 @@
 package gcs
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-)
+	"strings"
+	"time"
 
-func (s *GCS) detectMimeType(file io.Reader) (string, error) {
-	// Create a buffer to store the header of the file in
-	fileHeader := make([]byte, 512)
+	"cloud.google.com/go/storage"
+	gostorage "github.com/dptsi/go-storage"
+)
 
-	// Copy the headers into the FileHeader buffer
-	if _, err := file.Read(fileHeader); err != nil {
+func (s *GCS) detectMimeType(file *bufio.Reader) (string, error) {
+	// Peek at the header of the file without consuming it. Files smaller
+	// than 512 bytes return io.EOF, which isn't an error here.
+	fileHeader, err := file.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
 		return "", fmt.Errorf("failed to read file header: %w", err)
 	}
 
 	return http.DetectContentType(fileHeader), nil
 }
 
-// func (*GCS) getSize(stream io.Reader) int {
-// 	buf := new(bytes.Buffer)
-// 	buf.ReadFrom(stream)
-// 	return buf.Len()
-// }
+// emulatorEndpoint returns the JSON API endpoint of an emulator at host,
+// the same way storage.NewClient handles STORAGE_EMULATOR_HOST.
+func emulatorEndpoint(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	return strings.TrimSuffix(host, "/") + "/storage/v1/"
+}
+
+// parseSigner reads the key used to sign URLs from a service account key
+// file. Other credentials, such as authorized users, have no key and
+// return nil.
+func parseSigner(credentialsJSON []byte) (*signer, error) {
+	var key struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}
+	if err := json.Unmarshal(credentialsJSON, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, nil
+	}
+
+	return &signer{googleAccessID: key.ClientEmail, privateKey: []byte(key.PrivateKey)}, nil
+}
+
+func fileInfo(attrs *storage.ObjectAttrs) FileInfo {
+	return FileInfo{
+		FileID:       attrs.Name,
+		FileName:     attrs.Metadata[gostorage.MetadataName],
+		FileExt:      attrs.Metadata[gostorage.MetadataExt],
+		FileMimetype: attrs.ContentType,
+		FileSize:     int(attrs.Size),
+		ETag:         attrs.Etag,
+		Timestamp:    attrs.Updated.UTC().Format(time.RFC3339),
+		Metadata:     gostorage.CustomMetadata(attrs.Metadata),
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-
-	"github.com/cenkalti/backoff/v4"
 )
 
 type DeleteResponse struct {
@@ -22,21 +20,12 @@ func (u DeleteResponse) IsOk() bool {
 }
 
 func (s *StorageApi) Delete(ctx context.Context, fileId string) (DeleteResponse, error) {
-	client := &http.Client{}
-	url := fmt.Sprintf("%s/d/files/%s", s.storageApiUrl, fileId)
-	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
-	if err != nil {
-		return DeleteResponse{}, fmt.Errorf("failed to create request: %w", err)
-	}
-	if err := s.setAuthorizationHeader(ctx, req); err != nil {
-		return DeleteResponse{}, fmt.Errorf("failed to set authorization header: %w", err)
-	}
-
-	resp, err := backoff.RetryWithData[*http.Response](func() (*http.Response, error) {
-		return client.Do(req)
-	}, s.backoff)
+	resp, err := s.do(ctx, request{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/d/files/%s", s.storageApiUrl, fileId),
+	})
 	if err != nil {
-		return DeleteResponse{}, fmt.Errorf("failed to do request: %w", err)
+		return DeleteResponse{}, err
 	}
 	defer resp.Body.Close()
 
@@ -45,7 +34,7 @@ func (s *StorageApi) Delete(ctx context.Context, fileId string) (DeleteResponse,
 		if err != nil {
 			return DeleteResponse{}, fmt.Errorf("failed to read response body: %w", err)
 		}
-		return DeleteResponse{}, fmt.Errorf("failed to delete file: %s", string(body))
+		return DeleteResponse{}, fmt.Errorf("failed to delete file: %w", newResponseError(resp.StatusCode, body))
 	}
 
 	var deleteResponse DeleteResponse
@@ -54,7 +43,7 @@ func (s *StorageApi) Delete(ctx context.Context, fileId string) (DeleteResponse,
 	}
 
 	if !deleteResponse.IsOk() {
-		return DeleteResponse{}, fmt.Errorf("failed to delete file: %s", deleteResponse.Message)
+		return DeleteResponse{}, fmt.Errorf("failed to delete file: %w", newStatusError(resp.StatusCode, deleteResponse.Message))
 	}
 
 	return deleteResponse, nil
@@ -0,0 +1,47 @@
+package its
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	storage "github.com/dptsi/go-storage"
+)
+
+// newResponseError converts an error response body of the Storage API into
+// a *storage.StatusError.
+func newResponseError(statusCode int, body []byte) error {
+	var resp struct {
+		Message string `json:"message"`
+	}
+	message := string(body)
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Message != "" {
+		message = resp.Message
+	}
+
+	return newStatusError(statusCode, message)
+}
+
+// newStatusError returns a *storage.StatusError for the status code and
+// message. The error is mapped from the status code when it has a matching
+// error. The Storage API sometimes responds with 200 and a non-OK status,
+// so the message is only checked for statuses which don't decide the error
+// by themselves; server errors are never mapped from the message.
+func newStatusError(statusCode int, message string) error {
+	err := &storage.StatusError{StatusCode: statusCode, Message: message}
+	if storage.ErrorFromStatus(statusCode) != nil || statusCode >= http.StatusInternalServerError {
+		return err
+	}
+
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "not found"):
+		err.Err = storage.ErrNotFound
+	case strings.Contains(lower, "quota"):
+		err.Err = storage.ErrQuotaExceeded
+	case strings.Contains(lower, "too large"):
+		err.Err = storage.ErrTooLarge
+	}
+
+	return err
+}
@@ -0,0 +1,29 @@
+package its
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStatusError(t *testing.T) {
+	for _, tt := range []struct {
+		statusCode int
+		message    string
+		want       error
+	}{
+		{http.StatusOK, "file not found", storage.ErrNotFound},
+		{http.StatusBadRequest, "quota exceeded", storage.ErrQuotaExceeded},
+		{http.StatusNotFound, "file too large", storage.ErrNotFound},
+		{http.StatusForbidden, "file not found", storage.ErrForbidden},
+		{http.StatusInternalServerError, "file not found", nil},
+	} {
+		err := newStatusError(tt.statusCode, tt.message)
+		for _, sentinel := range []error{storage.ErrNotFound, storage.ErrQuotaExceeded, storage.ErrTooLarge, storage.ErrForbidden} {
+			assert.Equal(t, sentinel == tt.want, errors.Is(err, sentinel), "%d %q is %v", tt.statusCode, tt.message, sentinel)
+		}
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-
-	"github.com/cenkalti/backoff/v4"
 )
 
 type GetResponse struct {
@@ -22,21 +20,12 @@ func (u GetResponse) IsOk() bool {
 }
 
 func (s *StorageApi) Get(ctx context.Context, fileId string) (GetResponse, error) {
-	client := &http.Client{}
-	url := fmt.Sprintf("%s/d/files/%s", s.storageApiUrl, fileId)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return GetResponse{}, fmt.Errorf("failed to create request: %w", err)
-	}
-	if err := s.setAuthorizationHeader(ctx, req); err != nil {
-		return GetResponse{}, fmt.Errorf("failed to set authorization header: %w", err)
-	}
-
-	resp, err := backoff.RetryWithData[*http.Response](func() (*http.Response, error) {
-		return client.Do(req)
-	}, s.backoff)
+	resp, err := s.do(ctx, request{
+		method: "GET",
+		url:    fmt.Sprintf("%s/d/files/%s", s.storageApiUrl, fileId),
+	})
 	if err != nil {
-		return GetResponse{}, fmt.Errorf("failed to do request: %w", err)
+		return GetResponse{}, err
 	}
 	defer resp.Body.Close()
 
@@ -45,7 +34,7 @@ func (s *StorageApi) Get(ctx context.Context, fileId string) (GetResponse, error
 		if err != nil {
 			return GetResponse{}, fmt.Errorf("failed to read response body: %w", err)
 		}
-		return GetResponse{}, fmt.Errorf("failed to get file by id: %s", string(body))
+		return GetResponse{}, fmt.Errorf("failed to get file by id: %w", newResponseError(resp.StatusCode, body))
 	}
 
 	var getFileByIdResponse GetResponse
@@ -54,7 +43,7 @@ func (s *StorageApi) Get(ctx context.Context, fileId string) (GetResponse, error
 	}
 
 	if !getFileByIdResponse.IsOk() {
-		return GetResponse{}, fmt.Errorf("failed to get file by id: %s", getFileByIdResponse.Message)
+		return GetResponse{}, fmt.Errorf("failed to get file by id: %w", newStatusError(resp.StatusCode, getFileByIdResponse.Message))
 	}
 
 	return getFileByIdResponse, nil
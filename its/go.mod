@@ -4,12 +4,24 @@ go 1.21.5
 
 require (
 	github.com/cenkalti/backoff/v4 v4.2.1
+	github.com/dptsi/go-storage v0.1.0
+	github.com/google/uuid v1.6.0
+	github.com/stretchr/testify v1.8.4
 	golang.org/x/oauth2 v0.15.0
 )
 
 require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/net v0.19.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// The root module is required at the version it will be released as. Until
+// that tag is pushed, it is built from this checkout; remove the replace
+// and run go mod tidy once the tag is published.
+replace github.com/dptsi/go-storage => ../
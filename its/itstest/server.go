@@ -0,0 +1,294 @@
+// Package itstest provides a fake ITS Storage API and OpenID Connect provider
+// for tests which use its.StorageApi.
+package itstest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dptsi/go-storage/its"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultClientID     = "itstest-client"
+	DefaultClientSecret = "itstest-secret"
+
+	tokenPath = "/oauth2/token"
+	filesPath = "/d/files"
+)
+
+var validFileName = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+type file struct {
+	info its.FileInfo
+	data []byte
+}
+
+// Server is an httptest.Server which serves the OpenID Connect discovery
+// document, issues client credentials tokens and implements the /d/files
+// endpoints of the ITS Storage API. Both the OIDC provider and the Storage
+// API are served from the same URL.
+type Server struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+
+	// RetryAfter is sent as the Retry-After header of failures injected
+	// with FailNext, when not empty.
+	RetryAfter string
+
+	mu            sync.Mutex
+	tokens        map[string]bool
+	tokensIssued  int
+	files         map[string]file
+	requests      int
+	failures      int
+	failureStatus int
+}
+
+// NewServer starts a fake server accepting DefaultClientID and
+// DefaultClientSecret. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		ClientID:     DefaultClientID,
+		ClientSecret: DefaultClientSecret,
+		tokens:       make(map[string]bool),
+		files:        make(map[string]file),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleWellKnown)
+	mux.HandleFunc(tokenPath, s.handleToken)
+	mux.HandleFunc(filesPath, s.handleFiles)
+	mux.HandleFunc(filesPath+"/", s.handleFiles)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Config returns the its.Config to connect to the server.
+func (s *Server) Config() its.Config {
+	return its.Config{
+		ClientID:        s.ClientID,
+		ClientSecret:    s.ClientSecret,
+		OidcProviderURL: s.URL,
+		StorageApiURL:   s.URL,
+	}
+}
+
+// Files returns the number of files currently stored.
+func (s *Server) Files() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
+
+// TokensIssued returns the number of access tokens issued.
+func (s *Server) TokensIssued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokensIssued
+}
+
+// RevokeTokens invalidates every access token issued so far, so the Storage
+// API responds with 401 Unauthorized until a new token is used.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]bool)
+}
+
+// Requests returns the number of Storage API requests received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// FailNext makes the next n Storage API requests fail with statusCode.
+func (s *Server) FailNext(n, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+	s.failureStatus = statusCode
+}
+
+func (s *Server) handleWellKnown(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":         s.URL,
+		"token_endpoint": s.URL + tokenPath,
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	if clientId != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	token := randomToken()
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.tokensIssued++
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	s.mu.Unlock()
+	if fail {
+		if s.RetryAfter != "" {
+			w.Header().Set("Retry-After", s.RetryAfter)
+		}
+		writeJSON(w, s.failureStatus, envelope{Status: "ERROR", Message: "injected failure"})
+		return
+	}
+
+	if !s.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, envelope{Status: "ERROR", Message: "unauthorized"})
+		return
+	}
+
+	fileId := strings.Trim(strings.TrimPrefix(r.URL.Path, filesPath), "/")
+	switch {
+	case fileId == "" && r.Method == http.MethodPost:
+		s.upload(w, r)
+	case fileId != "" && r.Method == http.MethodGet:
+		s.get(w, fileId)
+	case fileId != "" && r.Method == http.MethodDelete:
+		s.delete(w, fileId)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, envelope{Status: "ERROR", Message: "method not allowed"})
+	}
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	var body its.UploadBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, envelope{Status: "ERROR", Message: "invalid request body"})
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(body.BinaryDataB64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, envelope{Status: "ERROR", Message: "invalid binary_data_b64"})
+		return
+	}
+	// Like the live API, which fails to store files whose name contains
+	// anything but ASCII letters and digits (fixed in its v1.0.2).
+	if !validFileName.MatchString(body.FileName) {
+		writeJSON(w, http.StatusBadRequest, envelope{Status: "ERROR", Message: "invalid file_name"})
+		return
+	}
+
+	fileId := uuid.NewString()
+	info := its.FileInfo{
+		FileExt:      body.FileExt,
+		FileID:       fileId,
+		FileMimetype: body.FileMimetype,
+		FileName:     body.FileName,
+		FileSize:     len(data),
+		PublicLink:   s.URL + "/public/" + fileId,
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+	}
+	s.mu.Lock()
+	s.files[fileId] = file{info: info, data: data}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, envelope{Status: "OK", FileID: fileId, Info: &info})
+}
+
+func (s *Server) get(w http.ResponseWriter, fileId string) {
+	s.mu.Lock()
+	f, ok := s.files[fileId]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, envelope{Status: "ERROR", Message: "file not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{
+		Status: "OK",
+		Info:   &f.info,
+		Data:   base64.StdEncoding.EncodeToString(f.data),
+	})
+}
+
+func (s *Server) delete(w http.ResponseWriter, fileId string) {
+	s.mu.Lock()
+	f, ok := s.files[fileId]
+	delete(s.files, fileId)
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, envelope{Status: "ERROR", Message: "file not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Status: "OK", FileID: fileId, Info: &f.info})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if r.Header.Get("x-client-id") != s.ClientID {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[r.Header.Get("x-code")]
+}
+
+// envelope is the JSON body every /d/files endpoint responds with. Info is
+// sent before data, so its.StorageApi.Stream doesn't need to buffer.
+type envelope struct {
+	Status  string        `json:"status"`
+	Message string        `json:"message,omitempty"`
+	FileID  string        `json:"file_id,omitempty"`
+	Info    *its.FileInfo `json:"info,omitempty"`
+	Data    string        `json:"data,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,19 +1,27 @@
 package its
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 )
 
-func getOidcWellKnownConfig(providerUrl string) (map[string]interface{}, error) {
+func getOidcWellKnownConfig(ctx context.Context, client *http.Client, providerUrl string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/.well-known/openid-configuration", providerUrl)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get oidc well-known config: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get oidc well-known config: status %d", resp.StatusCode)
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read oidc well-known config: %w", err)
@@ -26,15 +34,15 @@ func getOidcWellKnownConfig(providerUrl string) (map[string]interface{}, error)
 	return data, nil
 }
 
-func getOidcTokenEndpoint(providerUrl string) (string, error) {
-	data, err := getOidcWellKnownConfig(providerUrl)
+func getOidcTokenEndpoint(ctx context.Context, client *http.Client, providerUrl string) (string, error) {
+	data, err := getOidcWellKnownConfig(ctx, client, providerUrl)
 	if err != nil {
 		return "", fmt.Errorf("failed to get oidc token endpoint: %w", err)
 	}
 
 	tokenEndpoint, ok := data["token_endpoint"].(string)
 	if !ok {
-		return "", fmt.Errorf("failed to get oidc token endpoint: %w", err)
+		return "", fmt.Errorf("failed to get oidc token endpoint: token_endpoint is missing")
 	}
 
 	return tokenEndpoint, nil
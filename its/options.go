@@ -0,0 +1,113 @@
+package its
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	storage "github.com/dptsi/go-storage"
+)
+
+// Option configures a StorageApi created by NewStorageApi.
+type Option func(*options)
+
+type options struct {
+	httpClient     *http.Client
+	newBackoff     func() backoff.BackOff
+	timeout        time.Duration
+	userAgent      string
+	fileNamePolicy *storage.FileNamePolicy
+}
+
+// WithHTTPClient sets the client used for the OpenID Connect discovery, the
+// token endpoint and the Storage API, e.g. to configure proxies, mTLS,
+// custom CAs or instrumentation. The client isn't modified.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithBackoff sets the retry policy of Storage API requests. newBackoff is
+// called for every request, so it must return a new instance each time.
+func WithBackoff(newBackoff func() backoff.BackOff) Option {
+	return func(o *options) {
+		o.newBackoff = newBackoff
+	}
+}
+
+// WithTimeout sets the time limit of a single HTTP request, including
+// reading the response body.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithFileNamePolicy sets how the names of uploaded files are sanitized.
+// The Storage API rejects names with anything but ASCII letters and digits,
+// which is why v1.0.2 started removing any other character, so the default
+// policy still does. Policies producing other characters fail to upload.
+func WithFileNamePolicy(policy *storage.FileNamePolicy) Option {
+	return func(o *options) {
+		o.fileNamePolicy = policy
+	}
+}
+
+// defaultFileNamePolicy is storage.DefaultFileNamePolicy restricted to what
+// the Storage API accepts. Windows device names are valid for the API, and
+// the prefix and fallback of the default would add an underscore.
+func defaultFileNamePolicy() *storage.FileNamePolicy {
+	policy := storage.DefaultFileNamePolicy()
+	policy.Disallowed = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+	policy.Replacement = ""
+	policy.ReservedNames = nil
+	policy.Fallback = func() string {
+		return fmt.Sprintf("undefined%d", time.Now().Unix())
+	}
+
+	return policy
+}
+
+// client returns the HTTP client configured by the options.
+func (o options) client() *http.Client {
+	client := &http.Client{}
+	if o.httpClient != nil {
+		// Copy, so the caller's client isn't modified.
+		c := *o.httpClient
+		client = &c
+	}
+	if o.timeout > 0 {
+		client.Timeout = o.timeout
+	}
+	if o.userAgent != "" {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		client.Transport = &userAgentTransport{base: transport, userAgent: o.userAgent}
+	}
+
+	return client
+}
+
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
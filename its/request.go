@@ -0,0 +1,153 @@
+package its
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+func defaultBackoff() backoff.BackOff {
+	return backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 3)
+}
+
+// request is a Storage API call which can be sent more than once.
+type request struct {
+	method string
+	url    string
+
+	// newBody returns the body for an attempt. It is called once per
+	// attempt, so a body is never sent twice. nil means no body.
+	newBody func() (io.Reader, error)
+
+	// noRetry is set when the body can't be rebuilt.
+	noRetry bool
+}
+
+// do sends r, retrying on network errors and retryable status codes until
+// the backoff gives up or ctx is done. Any other response, including 4xx, is
+// returned to the caller as is.
+func (s *StorageApi) do(ctx context.Context, r request) (*http.Response, error) {
+	var b backoff.BackOff = &backoff.StopBackOff{}
+	if !r.noRetry {
+		b = s.newBackoff()
+	}
+	b = backoff.WithContext(b, ctx)
+	b.Reset()
+
+	refreshed := false
+	for {
+		var body io.Reader
+		if r.newBody != nil {
+			var err error
+			if body, err = r.newBody(); err != nil {
+				return nil, fmt.Errorf("failed to create request body: %w", err)
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
+		if err != nil {
+			closeBody(body)
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if err := s.setAuthorizationHeader(ctx, req); err != nil {
+			closeBody(body)
+			return nil, fmt.Errorf("failed to set authorization header: %w", err)
+		}
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("failed to do request: %w", ctx.Err())
+			}
+			next := b.NextBackOff()
+			if next == backoff.Stop {
+				return nil, fmt.Errorf("failed to do request: %w", err)
+			}
+			if err := sleep(ctx, next); err != nil {
+				return nil, fmt.Errorf("failed to do request: %w", err)
+			}
+			continue
+		}
+		// The cached token may have been revoked before it expired, refresh
+		// it once if the body can be rebuilt.
+		if resp.StatusCode == http.StatusUnauthorized && !refreshed && !r.noRetry {
+			refreshed = true
+			s.tokenSource.invalidate(req.Header.Get("x-code"))
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			continue
+		}
+		if !isRetryable(resp.StatusCode) {
+			return resp, nil
+		}
+
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return resp, nil
+		}
+		if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > next {
+			next = retryAfter
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err := sleep(ctx, next); err != nil {
+			return nil, fmt.Errorf("failed to do request: %w", err)
+		}
+	}
+}
+
+// closeBody closes a body which was never handed to the client, which would
+// have closed it otherwise.
+func closeBody(body io.Reader) {
+	if closer, ok := body.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func isRetryable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
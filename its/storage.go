@@ -0,0 +1,88 @@
+package its
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+)
+
+// Storage returns s as a backend-agnostic storage.Storage.
+//
+// The Storage API has no metadata-only endpoint, so FileInfo and PublicLink
+// start streaming the file and stop once its info is read. Public links are
+// managed by the Storage API itself, therefore the requested expiration is
+// ignored.
+func (s *StorageApi) Storage() storage.Storage {
+	return storageAdapter{s: s}
+}
+
+type storageAdapter struct {
+	s *StorageApi
+}
+
+func (a storageAdapter) Upload(ctx context.Context, file io.Reader, name, ext string) (storage.FileInfo, error) {
+	resp, err := a.s.UploadReader(ctx, file, name+ext)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+
+	return resp.Info.toStorage(), nil
+}
+
+func (a storageAdapter) Stream(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	content, _, err := a.s.Stream(ctx, fileId)
+	return content, err
+}
+
+func (a storageAdapter) FileInfo(ctx context.Context, fileId string) (storage.FileInfo, error) {
+	content, info, err := a.s.Stream(ctx, fileId)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+	content.Close()
+
+	return info.toStorage(), nil
+}
+
+func (a storageAdapter) PublicLink(
+	ctx context.Context,
+	fileId string,
+	expiration time.Duration,
+) (storage.PublicLinkResponse, error) {
+	content, info, err := a.s.Stream(ctx, fileId)
+	if err != nil {
+		return storage.PublicLinkResponse{}, err
+	}
+	content.Close()
+
+	return storage.PublicLinkResponse{Url: info.PublicLink}, nil
+}
+
+func (a storageAdapter) Delete(ctx context.Context, fileId string) error {
+	if _, err := a.s.Delete(ctx, fileId); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (f FileInfo) toStorage() storage.FileInfo {
+	// The Storage API stores the extension without the leading dot.
+	ext := f.FileExt
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	return storage.FileInfo{
+		FileID:       f.FileID,
+		FileName:     f.FileName,
+		FileExt:      ext,
+		FileMimetype: f.FileMimetype,
+		FileSize:     f.FileSize,
+		Timestamp:    f.Timestamp,
+	}
+}
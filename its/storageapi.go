@@ -3,8 +3,10 @@ package its
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/cenkalti/backoff/v4"
+	storage "github.com/dptsi/go-storage"
 	"golang.org/x/oauth2/clientcredentials"
 )
 
@@ -39,23 +41,39 @@ type Config struct {
 }
 
 type StorageApi struct {
-	oauth2Config  clientcredentials.Config
-	storageApiUrl string
-	backoff       backoff.BackOff
+	client         *http.Client
+	oauth2Config   clientcredentials.Config
+	tokenSource    *tokenSource
+	storageApiUrl  string
+	newBackoff     func() backoff.BackOff
+	fileNamePolicy *storage.FileNamePolicy
 }
 
-func NewStorageApi(ctx context.Context, config Config) (*StorageApi, error) {
-	tokenUrl, err := getOidcTokenEndpoint(config.OidcProviderURL)
+func NewStorageApi(ctx context.Context, config Config, opts ...Option) (*StorageApi, error) {
+	o := options{newBackoff: defaultBackoff}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.fileNamePolicy == nil {
+		o.fileNamePolicy = defaultFileNamePolicy()
+	}
+	client := o.client()
+
+	tokenUrl, err := getOidcTokenEndpoint(ctx, client, config.OidcProviderURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to instantiate storage api: %w", err)
 	}
+	oauth2Config := clientcredentials.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		TokenURL:     tokenUrl,
+	}
 	return &StorageApi{
-		oauth2Config: clientcredentials.Config{
-			ClientID:     config.ClientID,
-			ClientSecret: config.ClientSecret,
-			TokenURL:     tokenUrl,
-		},
-		storageApiUrl: config.StorageApiURL,
-		backoff:       backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 3),
+		client:         client,
+		oauth2Config:   oauth2Config,
+		tokenSource:    newTokenSource(client, oauth2Config),
+		storageApiUrl:  config.StorageApiURL,
+		newBackoff:     o.newBackoff,
+		fileNamePolicy: o.fileNamePolicy,
 	}, nil
 }
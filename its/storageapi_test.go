@@ -0,0 +1,486 @@
+package its_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	storage "github.com/dptsi/go-storage"
+	"github.com/dptsi/go-storage/its"
+	"github.com/dptsi/go-storage/its/itstest"
+	"github.com/dptsi/go-storage/storagetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleFile = []byte("%PDF-1.4\nsample file content")
+
+func fastBackoff() backoff.BackOff {
+	return backoff.WithMaxRetries(backoff.NewConstantBackOff(10*time.Millisecond), 3)
+}
+
+func getStorageApi(t *testing.T) (*its.StorageApi, *itstest.Server) {
+	server := itstest.NewServer()
+	t.Cleanup(server.Close)
+
+	storageApi, err := its.NewStorageApi(context.Background(), server.Config(), its.WithBackoff(fastBackoff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storageApi, server
+}
+
+func newFileHeader(t *testing.T, fileName string, content []byte) *multipart.FileHeader {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+func TestNewStorageApi(t *testing.T) {
+	server := itstest.NewServer()
+	defer server.Close()
+
+	_, err := its.NewStorageApi(context.Background(), server.Config())
+	assert.NoError(t, err)
+
+	config := server.Config()
+	config.OidcProviderURL = server.URL + "/missing"
+	_, err = its.NewStorageApi(context.Background(), config)
+	assert.Error(t, err)
+}
+
+type recordingTransport struct {
+	userAgents []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.userAgents = append(rt.userAgents, req.Header.Get("User-Agent"))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewStorageApiWithOptions(t *testing.T) {
+	ctx := context.Background()
+	server := itstest.NewServer()
+	defer server.Close()
+
+	transport := &recordingTransport{}
+	client := &http.Client{Transport: transport}
+	storageApi, err := its.NewStorageApi(
+		ctx,
+		server.Config(),
+		its.WithHTTPClient(client),
+		its.WithTimeout(time.Minute),
+		its.WithUserAgent("itstest/1.0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storageApi.Get(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Discovery, token and Storage API requests all use the client.
+	assert.Equal(t, []string{"itstest/1.0", "itstest/1.0", "itstest/1.0"}, transport.userAgents)
+	assert.Zero(t, client.Timeout, "the given client shouldn't be modified")
+}
+
+func TestUploadFile(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	resp, err := storageApi.Upload(ctx, newFileHeader(t, "sample-file.pdf", sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, resp.IsOk())
+	assert.NotEmpty(t, resp.FileID)
+	assert.Equal(t, resp.FileID, resp.Info.FileID)
+	assert.Equal(t, "samplefile", resp.Info.FileName)
+	assert.Equal(t, "pdf", resp.Info.FileExt)
+	assert.Equal(t, "application/pdf", resp.Info.FileMimetype)
+	assert.Equal(t, len(sampleFile), resp.Info.FileSize)
+	assert.NotEmpty(t, resp.Info.PublicLink)
+	assert.Equal(t, 1, server.Files())
+}
+
+func TestUploadReader(t *testing.T) {
+	ctx := context.Background()
+	storageApi, _ := getStorageApi(t)
+
+	// A pipe is neither seekable nor sized, so it must be streamed.
+	content := bytes.Repeat(sampleFile, 64*1024)
+	r, w := io.Pipe()
+	go func() {
+		_, err := io.Copy(w, bytes.NewReader(content))
+		w.CloseWithError(err)
+	}()
+	resp, err := storageApi.UploadReader(ctx, r, "large \"file\".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "largefile", resp.Info.FileName)
+	assert.Equal(t, "application/pdf", resp.Info.FileMimetype)
+	assert.Equal(t, len(content), resp.Info.FileSize)
+
+	got, err := storageApi.Get(ctx, resp.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, base64.StdEncoding.EncodeToString(content), got.Data)
+}
+
+func TestUploadWithFileNamePolicy(t *testing.T) {
+	ctx := context.Background()
+	server := itstest.NewServer()
+	defer server.Close()
+
+	policy := storage.DefaultFileNamePolicy()
+	policy.Replacement = ""
+	storageApi, err := its.NewStorageApi(ctx, server.Config(), its.WithFileNamePolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := storageApi.UploadReader(ctx, bytes.NewReader(sampleFile), "Résumé final.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Resumefinal", resp.Info.FileName)
+
+	_, err = storageApi.UploadReader(ctx, bytes.NewReader(sampleFile), "../secret.pdf")
+	assert.ErrorIs(t, err, storage.ErrInvalidFileName)
+	assert.Equal(t, 1, server.Files())
+}
+
+func TestDefaultFileNamePolicyIsAccepted(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	resp, err := storageApi.UploadReader(ctx, bytes.NewReader(sampleFile), "my-report_v2 (final).pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "myreportv2final", resp.Info.FileName)
+	resp, err = storageApi.UploadReader(ctx, bytes.NewReader(sampleFile), "con.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "con", resp.Info.FileName)
+	resp, err = storageApi.UploadReader(ctx, bytes.NewReader(sampleFile), "日本.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Regexp(t, `^undefined[0-9]+$`, resp.Info.FileName)
+
+	// The shared default keeps dashes and underscores, which the Storage
+	// API rejects.
+	storageApi, err = its.NewStorageApi(ctx, server.Config(),
+		its.WithBackoff(fastBackoff),
+		its.WithFileNamePolicy(storage.DefaultFileNamePolicy()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storageApi.UploadReader(ctx, bytes.NewReader(sampleFile), "my-report_v2 (final).pdf")
+	var statusErr *storage.StatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+	}
+	assert.Equal(t, 3, server.Files())
+}
+
+func TestUploadFileWithWrongCredentials(t *testing.T) {
+	ctx := context.Background()
+	server := itstest.NewServer()
+	defer server.Close()
+
+	config := server.Config()
+	config.ClientSecret = "wrong"
+	storageApi, err := its.NewStorageApi(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+	assert.ErrorIs(t, err, storage.ErrUnauthorized)
+	assert.Equal(t, 0, server.Files())
+}
+
+func TestUploadFileRetry(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	// The body has to be rebuilt for every attempt.
+	server.FailNext(2, http.StatusServiceUnavailable)
+	resp, err := storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, server.Requests())
+
+	got, err := storageApi.Get(ctx, resp.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sampleFile), got.Data)
+}
+
+func TestUploadReaderIsNotRetried(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	server.FailNext(1, http.StatusServiceUnavailable)
+	_, err := storageApi.UploadReader(ctx, io.MultiReader(bytes.NewReader(sampleFile)), "sample.pdf")
+	assert.Error(t, err)
+	assert.Equal(t, 1, server.Requests())
+}
+
+func TestRequestIsNotRetriedOnClientError(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	server.FailNext(1, http.StatusBadRequest)
+	_, err := storageApi.Get(ctx, "missing")
+	var statusErr *storage.StatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+	}
+	assert.Equal(t, 1, server.Requests())
+}
+
+func TestRequestHonoursRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	server.RetryAfter = "1"
+	server.FailNext(1, http.StatusTooManyRequests)
+	start := time.Now()
+	_, err := storageApi.Get(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Equal(t, 2, server.Requests())
+}
+
+func TestRequestStopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	storageApi, server := getStorageApi(t)
+
+	server.RetryAfter = "60"
+	server.FailNext(1, http.StatusServiceUnavailable)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err := storageApi.Get(ctx, "missing")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, server.Requests())
+}
+
+func TestTokenIsReused(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	for i := 0; i < 3; i++ {
+		resp, err := storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := storageApi.Get(ctx, resp.FileID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, 1, server.TokensIssued())
+}
+
+func TestTokenIsRefreshedOnUnauthorized(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	resp, err := storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.RevokeTokens()
+	_, err = storageApi.Get(ctx, resp.FileID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.TokensIssued())
+}
+
+func TestTokenFetchStopsOnContextCancel(t *testing.T) {
+	// The token endpoint never responds.
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"token_endpoint":%q}`, server.URL+"/token")
+	})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+
+	storageApi, err := its.NewStorageApi(context.Background(), its.Config{
+		ClientID:        itstest.DefaultClientID,
+		ClientSecret:    itstest.DefaultClientSecret,
+		OidcProviderURL: server.URL,
+		StorageApiURL:   server.URL,
+	}, its.WithBackoff(fastBackoff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent callers waiting for the same fetch give up with their own
+	// context.
+	errs := make(chan error, 2)
+	for _, timeout := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		go func(timeout time.Duration) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			_, err := storageApi.Get(ctx, "file")
+			errs <- err
+		}(timeout)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		case <-time.After(5 * time.Second):
+			t.Fatal("token fetch wasn't cancelled")
+		}
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	ctx := context.Background()
+	storageApi, _ := getStorageApi(t)
+
+	uploaded, err := storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := storageApi.Get(ctx, uploaded.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, resp.IsOk())
+	assert.Equal(t, uploaded.Info, resp.Info)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sampleFile), resp.Data)
+
+	_, err = storageApi.Get(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	var statusErr *storage.StatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		assert.Equal(t, "file not found", statusErr.Message)
+	}
+}
+
+func TestStreamFile(t *testing.T) {
+	ctx := context.Background()
+	storageApi, _ := getStorageApi(t)
+
+	uploaded, err := storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, info, err := storageApi.Stream(ctx, uploaded.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+	fileBytes, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uploaded.Info, info)
+	assert.Equal(t, sampleFile, fileBytes)
+
+	_, _, err = storageApi.Stream(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestDownloadToFile(t *testing.T) {
+	ctx := context.Background()
+	storageApi, _ := getStorageApi(t)
+
+	uploaded, err := storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := storageApi.DownloadToFile(ctx, uploaded.FileID, path.Join(t.TempDir(), uploaded.FileID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sampleFile, fileBytes)
+
+	buf := new(bytes.Buffer)
+	info, err := storageApi.DownloadTo(ctx, uploaded.FileID, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uploaded.Info, info)
+	assert.Equal(t, sampleFile, buf.Bytes())
+}
+
+func TestDeleteFile(t *testing.T) {
+	ctx := context.Background()
+	storageApi, server := getStorageApi(t)
+
+	uploaded, err := storageApi.Upload(ctx, newFileHeader(t, "sample.pdf", sampleFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := storageApi.Delete(ctx, uploaded.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, resp.IsOk())
+	assert.Equal(t, uploaded.FileID, resp.FileID)
+	assert.Equal(t, 0, server.Files())
+
+	_, err = storageApi.Get(ctx, uploaded.FileID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		storageApi, _ := getStorageApi(t)
+		return storageApi.Storage()
+	})
+}
@@ -0,0 +1,292 @@
+package its
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Stream returns the content of the file and its info. The base64 encoded
+// data is decoded while it is read, so the file is never held in memory as a
+// whole as long as the Storage API sends info before data. The caller must
+// close the returned reader.
+func (s *StorageApi) Stream(ctx context.Context, fileId string) (io.ReadCloser, FileInfo, error) {
+	resp, err := s.do(ctx, request{
+		method: "GET",
+		url:    fmt.Sprintf("%s/d/files/%s", s.storageApiUrl, fileId),
+	})
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, FileInfo{}, fmt.Errorf("failed to read response body: %w", err)
+		}
+		return nil, FileInfo{}, fmt.Errorf("failed to get file by id: %w", newResponseError(resp.StatusCode, body))
+	}
+
+	content, info, err := decodeStreamResponse(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, FileInfo{}, fmt.Errorf("failed to get file by id: %w", err)
+	}
+
+	return content, info, nil
+}
+
+// DownloadTo writes the content of the file to w.
+func (s *StorageApi) DownloadTo(ctx context.Context, fileId string, w io.Writer) (FileInfo, error) {
+	content, info, err := s.Stream(ctx, fileId)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer content.Close()
+
+	if _, err := io.Copy(w, content); err != nil {
+		return FileInfo{}, fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	return info, nil
+}
+
+// DownloadToFile writes the content of the file to path and returns the
+// created file, positioned at the start.
+func (s *StorageApi) DownloadToFile(ctx context.Context, fileId, path string) (*os.File, error) {
+	content, _, err := s.Stream(ctx, fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file to path %s: %w", path, err)
+	}
+
+	if _, err := io.Copy(file, content); err != nil {
+		return nil, fmt.Errorf("failed to copy file to path %s: %w", path, err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	return file, nil
+}
+
+// decodeStreamResponse decodes a GetResponse from body without holding data
+// in memory. When info comes after data, data has to be buffered.
+func decodeStreamResponse(body io.ReadCloser) (io.ReadCloser, FileInfo, error) {
+	var (
+		resp     GetResponse
+		infoSeen bool
+		data     []byte
+		dataSeen bool
+	)
+	var r io.Reader = body
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, FileInfo{}, err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, FileInfo{}, fmt.Errorf("failed to decode response: %w", err)
+		}
+		switch token {
+		case "info":
+			err = dec.Decode(&resp.Info)
+			infoSeen = true
+		case "status":
+			err = dec.Decode(&resp.Status)
+		case "message":
+			err = dec.Decode(&resp.Message)
+		case "data":
+			if resp.Status != "" && !resp.IsOk() {
+				return nil, FileInfo{}, newStatusError(http.StatusOK, resp.Message)
+			}
+			br := bufio.NewReader(io.MultiReader(dec.Buffered(), r))
+			if err := skipTo(br, ':', '"'); err != nil {
+				return nil, FileInfo{}, fmt.Errorf("failed to decode response: %w", err)
+			}
+			content := base64.NewDecoder(base64.StdEncoding, &jsonStringReader{r: br})
+			if infoSeen {
+				return readCloser{Reader: content, Closer: body}, resp.Info, nil
+			}
+
+			if data, err = io.ReadAll(content); err != nil {
+				return nil, FileInfo{}, fmt.Errorf("failed to decode data: %w", err)
+			}
+			dataSeen = true
+			// Decode the remaining fields as if they were a new object.
+			r, err = remainingObject(br)
+			if err != nil {
+				return nil, FileInfo{}, fmt.Errorf("failed to decode response: %w", err)
+			}
+			dec = json.NewDecoder(r)
+			err = expectDelim(dec, '{')
+		default:
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+		}
+		if err != nil {
+			return nil, FileInfo{}, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	body.Close()
+
+	if !resp.IsOk() {
+		return nil, FileInfo{}, newStatusError(http.StatusOK, resp.Message)
+	}
+	if !dataSeen {
+		return nil, FileInfo{}, errors.New("failed to decode response: missing data")
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), resp.Info, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if token != delim {
+		return fmt.Errorf("failed to decode response: expected %s, got %v", delim, token)
+	}
+
+	return nil
+}
+
+// skipTo consumes whitespace and the expected bytes from r.
+func skipTo(r *bufio.Reader, expected ...byte) error {
+	for _, e := range expected {
+		b, err := readNonSpace(r)
+		if err != nil {
+			return err
+		}
+		if b != e {
+			return fmt.Errorf("expected %q, got %q", e, b)
+		}
+	}
+
+	return nil
+}
+
+// remainingObject returns the fields following a field value in r as a
+// standalone JSON object.
+func remainingObject(r *bufio.Reader) (io.Reader, error) {
+	b, err := readNonSpace(r)
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case ',':
+		return io.MultiReader(strings.NewReader("{"), r), nil
+	case '}':
+		return strings.NewReader("{}"), nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q after data", b)
+}
+
+func readNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return b, nil
+	}
+}
+
+// jsonStringReader reads the unescaped content of a JSON string from r,
+// which must be positioned right after the opening quote. It returns
+// io.EOF at the closing quote.
+type jsonStringReader struct {
+	r    *bufio.Reader
+	done bool
+}
+
+func (j *jsonStringReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && !j.done {
+		b, err := j.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		switch b {
+		case '"':
+			j.done = true
+			continue
+		case '\\':
+			if b, err = j.unescape(); err != nil {
+				return n, err
+			}
+		}
+		p[n] = b
+		n++
+	}
+	if j.done && n == 0 {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// unescape reads an escape sequence after the backslash. Only escapes of
+// ASCII characters can appear in base64 data.
+func (j *jsonStringReader) unescape() (byte, error) {
+	b, err := j.r.ReadByte()
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	switch b {
+	case '"', '\\', '/':
+		return b, nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		code := make([]byte, 4)
+		if _, err := io.ReadFull(j.r, code); err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		decoded, err := hex.DecodeString(string(code))
+		if err != nil || decoded[0] != 0 || decoded[1] >= 0x80 {
+			return 0, fmt.Errorf("unexpected escape \\u%s in data", code)
+		}
+		return decoded[1], nil
+	}
+
+	return 0, fmt.Errorf("invalid escape \\%c in data", b)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,49 @@
+package its
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeStreamResponse(t *testing.T) {
+	raw := []byte("hello world\xff\xff\xff")
+	// Some JSON encoders escape slashes, which appear in base64 data.
+	data := strings.ReplaceAll(base64.StdEncoding.EncodeToString(raw), "/", `\/`)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"InfoFirst", `{"status":"OK","info":{"file_id":"1"},"data":"` + data + `"}`},
+		{"DataFirst", `{"data" : "` + data + `", "info": {"file_id": "1"}, "status": "OK"}`},
+		{"DataLast", `{"info":{"file_id":"1"},"status":"OK","data":"` + data + `"}`},
+		{"DataFirstNoFields", `{"status":"OK","data":"` + data + `" }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, info, err := decodeStreamResponse(io.NopCloser(strings.NewReader(tt.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer content.Close()
+
+			got, err := io.ReadAll(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, raw, got)
+			if tt.name != "DataFirstNoFields" {
+				assert.Equal(t, "1", info.FileID)
+			}
+		})
+	}
+}
+
+func TestDecodeStreamResponseError(t *testing.T) {
+	body := `{"status":"ERROR","message":"file not found"}`
+	_, _, err := decodeStreamResponse(io.NopCloser(strings.NewReader(body)))
+	assert.ErrorContains(t, err, "file not found")
+}
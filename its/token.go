@@ -0,0 +1,101 @@
+package its
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// tokenExpiryDelta is how long before its expiry a cached token is refreshed.
+const tokenExpiryDelta = time.Minute
+
+// tokenSource caches the client credentials token, so it is shared by all
+// requests instead of being fetched for every request. Concurrent callers
+// share a single fetch, but each of them waits for it only as long as its
+// own context allows. It is safe for concurrent use.
+type tokenSource struct {
+	client *http.Client
+	config clientcredentials.Config
+
+	mu    sync.Mutex
+	token *oauth2.Token
+	fetch *tokenFetch
+}
+
+// tokenFetch is a token request in flight. done is closed once token and
+// err are set.
+type tokenFetch struct {
+	done  chan struct{}
+	token *oauth2.Token
+	err   error
+}
+
+func newTokenSource(client *http.Client, config clientcredentials.Config) *tokenSource {
+	return &tokenSource{client: client, config: config}
+}
+
+// Token returns the cached token, fetching a new one with ctx when there is
+// none or it is about to expire. The lock isn't held while fetching.
+func (ts *tokenSource) Token(ctx context.Context) (*oauth2.Token, error) {
+	for {
+		ts.mu.Lock()
+		if ts.token != nil && ts.token.AccessToken != "" &&
+			(ts.token.Expiry.IsZero() || time.Until(ts.token.Expiry) > tokenExpiryDelta) {
+			token := ts.token
+			ts.mu.Unlock()
+			return token, nil
+		}
+		fetch := ts.fetch
+		if fetch == nil {
+			fetch = &tokenFetch{done: make(chan struct{})}
+			ts.fetch = fetch
+			ts.mu.Unlock()
+			ts.run(ctx, fetch)
+			return fetch.token, fetch.err
+		}
+		ts.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-fetch.done:
+		}
+		if fetch.err == nil {
+			return fetch.token, nil
+		}
+		// A fetch given up by its caller is retried with ctx, any other
+		// error is shared.
+		if !errors.Is(fetch.err, context.Canceled) && !errors.Is(fetch.err, context.DeadlineExceeded) {
+			return nil, fetch.err
+		}
+	}
+}
+
+// run fetches a token for fetch and caches it.
+func (ts *tokenSource) run(ctx context.Context, fetch *tokenFetch) {
+	fetch.token, fetch.err = ts.config.Token(context.WithValue(ctx, oauth2.HTTPClient, ts.client))
+
+	ts.mu.Lock()
+	if fetch.err == nil {
+		ts.token = fetch.token
+	}
+	ts.fetch = nil
+	ts.mu.Unlock()
+	close(fetch.done)
+}
+
+// invalidate drops the cached token if it is still accessToken, so the next
+// call to Token fetches a new one. Requests which raced with a refresh
+// don't throw away the fresh token.
+func (ts *tokenSource) invalidate(accessToken string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != nil && ts.token.AccessToken == accessToken {
+		ts.token = nil
+	}
+}
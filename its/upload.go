@@ -1,6 +1,7 @@
 package its
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -10,11 +11,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
-	"regexp"
 	"strings"
-	"time"
-
-	"github.com/cenkalti/backoff/v4"
 )
 
 type UploadResponse struct {
@@ -36,60 +33,86 @@ type UploadBody struct {
 }
 
 func (s *StorageApi) Upload(ctx context.Context, fileHeader *multipart.FileHeader) (UploadResponse, error) {
-	fileExt := filepath.Ext(fileHeader.Filename)
-
-	fileName := strings.TrimSuffix(fileHeader.Filename, fileExt)
-	rgx := regexp.MustCompile(`[^a-zA-Z0-9]+`)
-	fileNameByte := rgx.ReplaceAll([]byte(fileName), []byte(""))
-	fileName = string(fileNameByte)
-	if fileName == "" {
-		fileName = fmt.Sprintf("undefined_%d", time.Now().Unix())
-	}
-
 	file, err := fileHeader.Open()
 	if err != nil {
 		return UploadResponse{}, fmt.Errorf("failed to open file: %w", err)
 	}
-	fileExtWithoutDot := strings.TrimPrefix(fileExt, ".")
-	mime, err := s.detectMimeType(file)
-	if err != nil {
-		return UploadResponse{}, fmt.Errorf("failed to detect mime type: %w", err)
-	}
+	defer file.Close()
 
-	// Convert file to base64 string.
-	file.Seek(0, 0)
-	fileBytes, err := io.ReadAll(file)
+	return s.UploadReader(ctx, file, fileHeader.Filename)
+}
+
+// UploadReader uploads the content of r with name as the file name,
+// including the extension. The content is base64 encoded while the request
+// is being sent, so the file is never held in memory as a whole.
+func (s *StorageApi) UploadReader(ctx context.Context, r io.Reader, name string) (UploadResponse, error) {
+	fileExt := filepath.Ext(name)
+
+	fileName, err := s.fileNamePolicy.Sanitize(strings.TrimSuffix(name, fileExt))
 	if err != nil {
-		return UploadResponse{}, fmt.Errorf("failed to read file: %w", err)
+		return UploadResponse{}, fmt.Errorf("failed to sanitize file name: %w", err)
 	}
 
-	uploadBody := UploadBody{
-		FileName:      fileName,
-		FileExt:       fileExtWithoutDot,
-		FileMimetype:  mime,
-		BinaryDataB64: base64.StdEncoding.EncodeToString(fileBytes),
+	// Seekable files are read from the start for every attempt, anything
+	// else can be sent only once.
+	seeker, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return UploadResponse{}, fmt.Errorf("failed to seek file: %w", err)
+		}
+		start = offset
 	}
-	uploadBodyJson, err := json.Marshal(uploadBody)
+	buffered := bufio.NewReader(r)
+	fileExtWithoutDot := strings.TrimPrefix(fileExt, ".")
+	mime, err := s.detectMimeType(buffered)
 	if err != nil {
-		return UploadResponse{}, fmt.Errorf("failed to marshal upload body: %w", err)
+		return UploadResponse{}, fmt.Errorf("failed to detect mime type: %w", err)
 	}
 
-	client := &http.Client{}
-	url := fmt.Sprintf("%s/d/files", s.storageApiUrl)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(uploadBodyJson))
-	if err != nil {
-		return UploadResponse{}, fmt.Errorf("failed to create request: %w", err)
+	uploadBody := UploadBody{
+		FileName:     fileName,
+		FileExt:      fileExtWithoutDot,
+		FileMimetype: mime,
 	}
-	if err := s.setAuthorizationHeader(ctx, req); err != nil {
-		return UploadResponse{}, fmt.Errorf("failed to set authorization header: %w", err)
+	// writing is closed once the goroutine encoding the body of the
+	// previous attempt has stopped reading the file.
+	var writing chan struct{}
+	defer func() {
+		if writing != nil {
+			<-writing
+		}
+	}()
+	newBody := func() (io.Reader, error) {
+		var file io.Reader = buffered
+		if seekable {
+			if writing != nil {
+				<-writing
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("failed to seek file: %w", err)
+			}
+			file = r
+		}
+		body, bodyWriter := io.Pipe()
+		done := make(chan struct{})
+		writing = done
+		go func() {
+			defer close(done)
+			bodyWriter.CloseWithError(writeUploadBody(bodyWriter, uploadBody, file))
+		}()
+		return body, nil
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := backoff.RetryWithData[*http.Response](func() (*http.Response, error) {
-		return client.Do(req)
-	}, s.backoff)
+	resp, err := s.do(ctx, request{
+		method:  "POST",
+		url:     fmt.Sprintf("%s/d/files", s.storageApiUrl),
+		newBody: newBody,
+		noRetry: !seekable,
+	})
 	if err != nil {
-		return UploadResponse{}, fmt.Errorf("failed to do request: %w", err)
+		return UploadResponse{}, err
 	}
 	defer resp.Body.Close()
 
@@ -98,7 +121,7 @@ func (s *StorageApi) Upload(ctx context.Context, fileHeader *multipart.FileHeade
 		if err != nil {
 			return UploadResponse{}, fmt.Errorf("failed to read response body: %w", err)
 		}
-		return UploadResponse{}, fmt.Errorf("failed to upload file: %s", string(body))
+		return UploadResponse{}, fmt.Errorf("failed to upload file: %w", newResponseError(resp.StatusCode, body))
 	}
 
 	var uploadResponse UploadResponse
@@ -107,8 +130,40 @@ func (s *StorageApi) Upload(ctx context.Context, fileHeader *multipart.FileHeade
 	}
 
 	if !uploadResponse.IsOk() {
-		return UploadResponse{}, fmt.Errorf("failed to upload file: %s", uploadResponse.Message)
+		return UploadResponse{}, fmt.Errorf("failed to upload file: %w", newStatusError(resp.StatusCode, uploadResponse.Message))
 	}
 
 	return uploadResponse, nil
 }
+
+// writeUploadBody writes uploadBody as JSON to w with the content of file
+// base64 encoded into binary_data_b64.
+func writeUploadBody(w io.Writer, uploadBody UploadBody, file io.Reader) error {
+	// BinaryDataB64 is the last field, so everything before its empty
+	// value is the prefix of the document.
+	uploadBody.BinaryDataB64 = ""
+	uploadBodyJson, err := json.Marshal(uploadBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload body: %w", err)
+	}
+	prefix, ok := bytes.CutSuffix(uploadBodyJson, []byte(`""}`))
+	if !ok {
+		return fmt.Errorf("failed to marshal upload body: unexpected json %s", uploadBodyJson)
+	}
+
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `"`); err != nil {
+		return err
+	}
+	encoder := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := io.Copy(encoder, file); err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, `"}`)
+	return err
+}
@@ -1,32 +1,36 @@
 package its
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"mime/multipart"
+	"io"
 	"net/http"
-)
 
-func (s *StorageApi) detectMimeType(file multipart.File) (string, error) {
-	// Create a buffer to store the header of the file in
-	fileHeader := make([]byte, 512)
+	storage "github.com/dptsi/go-storage"
+	"golang.org/x/oauth2"
+)
 
-	// Copy the headers into the FileHeader buffer
-	if _, err := file.Read(fileHeader); err != nil {
+func (s *StorageApi) detectMimeType(file *bufio.Reader) (string, error) {
+	// Peek at the header of the file without consuming it. Files smaller
+	// than 512 bytes return io.EOF, which isn't an error here.
+	fileHeader, err := file.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
 		return "", fmt.Errorf("failed to read file header: %w", err)
 	}
 
-	// set position back to start.
-	if _, err := file.Seek(0, 0); err != nil {
-		return "", fmt.Errorf("failed to seek file: %w", err)
-	}
-
 	return http.DetectContentType(fileHeader), nil
 }
 
 func (s *StorageApi) setAuthorizationHeader(ctx context.Context, req *http.Request) error {
-	token, err := s.oauth2Config.TokenSource(ctx).Token()
+	token, err := s.tokenSource.Token(ctx)
 	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.Response != nil &&
+			(retrieveErr.Response.StatusCode == http.StatusBadRequest || retrieveErr.Response.StatusCode == http.StatusUnauthorized) {
+			return fmt.Errorf("failed to get token: %w: %w", storage.ErrUnauthorized, err)
+		}
 		return fmt.Errorf("failed to get token: %w", err)
 	}
 	req.Header.Set("x-client-id", s.oauth2Config.ClientID)
@@ -0,0 +1,56 @@
+package storage
+
+import "context"
+
+// ListOptions selects the files returned by Lister.List.
+type ListOptions struct {
+	// Prefix limits the result to the files whose ID starts with it.
+	Prefix string
+
+	// Delimiter groups the files whose ID contains it after Prefix into
+	// ListResult.Prefixes, like directories. Nothing is grouped when empty.
+	Delimiter string
+
+	// PageToken is the ListResult.NextPageToken of the previous page, empty
+	// for the first page.
+	PageToken string
+
+	// PageSize is the maximum number of files and prefixes per page. The
+	// backend chooses when zero, typically 1000.
+	PageSize int
+}
+
+type ListResult struct {
+	Files    []FileInfo `json:"files"`
+	Prefixes []string   `json:"prefixes,omitempty"`
+
+	// NextPageToken is empty on the last page.
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// Lister is implemented by backends which can enumerate their files, in
+// lexicographic order of their ID.
+type Lister interface {
+	List(ctx context.Context, opts ListOptions) (ListResult, error)
+}
+
+// Walk calls fn for every file matching opts, fetching the pages as they
+// are needed, until fn returns an error. opts.PageToken is the page to
+// start from.
+func Walk(ctx context.Context, l Lister, opts ListOptions, fn func(FileInfo) error) error {
+	for {
+		result, err := l.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, file := range result.Files {
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+		if result.NextPageToken == "" {
+			return nil
+		}
+		opts.PageToken = result.NextPageToken
+	}
+}
@@ -0,0 +1,35 @@
+//go:build go1.23
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// errStopWalk stops Walk when the loop over All is left early.
+var errStopWalk = errors.New("stop walk")
+
+// All returns an iterator over every file matching opts, fetching the pages
+// as they are needed. An error ends the iteration after being yielded.
+//
+//	for file, err := range storage.All(ctx, backend, storage.ListOptions{Prefix: "reports/"}) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func All(ctx context.Context, l Lister, opts ListOptions) iter.Seq2[FileInfo, error] {
+	return func(yield func(FileInfo, error) bool) {
+		err := Walk(ctx, l, opts, func(file FileInfo) error {
+			if !yield(file, nil) {
+				return errStopWalk
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopWalk) {
+			yield(FileInfo{}, err)
+		}
+	}
+}
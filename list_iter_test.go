@@ -0,0 +1,27 @@
+//go:build go1.23
+
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll(t *testing.T) {
+	m := newListedMemory(t, "a", "b", "c", "d", "e")
+
+	var ids []string
+	for file, err := range storage.All(context.Background(), m, storage.ListOptions{PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, file.FileID)
+		if file.FileID == "d" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids)
+}
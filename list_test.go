@@ -0,0 +1,81 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/dptsi/go-storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// newListedMemory returns a memory backend storing a file under every ID.
+func newListedMemory(t *testing.T, ids ...string) *memory.Memory {
+	next := 0
+	m := memory.NewMemory(memory.Config{NewID: func() string {
+		next++
+		return ids[next-1]
+	}})
+	for range ids {
+		if _, err := m.Upload(context.Background(), bytes.NewReader(samplePDF), "sample", ".pdf"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func fileIDs(files []storage.FileInfo) []string {
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.FileID)
+	}
+	return ids
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	m := newListedMemory(t, "reports/2023/a", "reports/2024/b", "reports/c", "images/d")
+
+	result, err := m.List(ctx, storage.ListOptions{Prefix: "reports/", Delimiter: "/", PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, result.Files)
+	assert.Equal(t, []string{"reports/2023/", "reports/2024/"}, result.Prefixes)
+	assert.NotEmpty(t, result.NextPageToken)
+
+	result, err = m.List(ctx, storage.ListOptions{Prefix: "reports/", Delimiter: "/", PageSize: 2, PageToken: result.NextPageToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"reports/c"}, fileIDs(result.Files))
+	assert.Empty(t, result.Prefixes)
+	assert.Empty(t, result.NextPageToken)
+}
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	m := newListedMemory(t, "a", "b", "c", "d", "e")
+
+	var ids []string
+	err := storage.Walk(ctx, m, storage.ListOptions{PageSize: 2}, func(file storage.FileInfo) error {
+		ids = append(ids, file.FileID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids)
+
+	stop := errors.New("stop")
+	ids = nil
+	err = storage.Walk(ctx, m, storage.ListOptions{PageSize: 2}, func(file storage.FileInfo) error {
+		ids = append(ids, file.FileID)
+		if file.FileID == "c" {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+}
@@ -0,0 +1,86 @@
+package local
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+const (
+	expiresParam   = "expires"
+	signatureParam = "signature"
+)
+
+// Handler serves the files behind public links. Mount it at Config.BaseURL,
+// e.g. with http.StripPrefix. Requests with a missing, invalid or expired
+// signature are rejected with 403 Forbidden.
+func (l *Local) Handler() http.Handler {
+	return http.HandlerFunc(l.serveHTTP)
+}
+
+func (l *Local) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	fileId := path.Base(r.URL.Path)
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get(expiresParam), 10, 64)
+	if err != nil || time.Now().Unix() > expires || !l.verify(fileId, expires, query.Get(signatureParam)) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	info, err := l.FileInfo(r.Context(), fileId)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := l.open(fileId)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	modTime, _ := time.Parse(time.RFC3339, info.Timestamp)
+	w.Header().Set("Content-Type", info.FileMimetype)
+	w.Header().Set("ETag", info.ETag)
+	http.ServeContent(w, r, info.FileName+info.FileExt, modTime, file)
+}
+
+func (l *Local) signedURL(fileId string, expiredAt time.Time) string {
+	expires := expiredAt.Unix()
+	query := url.Values{}
+	query.Set(expiresParam, strconv.FormatInt(expires, 10))
+	query.Set(signatureParam, l.sign(fileId, expires))
+
+	return fmt.Sprintf("%s%s?%s", l.baseURL, fileId, query.Encode())
+}
+
+func (l *Local) sign(fileId string, expires int64) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	fmt.Fprintf(mac, "%s\n%d", fileId, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l *Local) verify(fileId string, expires int64, signature string) bool {
+	expected, err := hex.DecodeString(l.sign(fileId, expires))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(expected, actual)
+}
@@ -0,0 +1,258 @@
+// Package local provides a storage backend which keeps files on the local
+// filesystem, so applications can run without S3, GCS or the ITS Storage API.
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/google/uuid"
+)
+
+const metadataExt = ".json"
+
+var _ storage.Storage = (*Local)(nil)
+
+type Config struct {
+	// Root is the directory the files are stored in. It is created if it
+	// doesn't exist yet.
+	Root string
+
+	// BaseURL is the URL Handler is mounted at, e.g.
+	// "http://localhost:8080/files/". Public links are BaseURL followed by
+	// the file ID and the signature.
+	BaseURL string
+
+	// SigningKey is the HMAC key used to sign public links. When empty, a
+	// random key is generated, so links don't survive a restart.
+	SigningKey []byte
+}
+
+type Local struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+func NewLocal(ctx context.Context, cfg Config) (*Local, error) {
+	if err := os.MkdirAll(cfg.Root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create root directory %s: %w", cfg.Root, err)
+	}
+	signingKey := cfg.SigningKey
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+	}
+
+	return &Local{
+		root:       cfg.Root,
+		baseURL:    cfg.BaseURL,
+		signingKey: signingKey,
+	}, nil
+}
+
+func (l *Local) Upload(ctx context.Context, file io.Reader, name, ext string) (storage.FileInfo, error) {
+	br := bufio.NewReaderSize(file, 512)
+	// Peek returns io.EOF for files smaller than 512 bytes, which is fine.
+	header, _ := br.Peek(512)
+	mime := http.DetectContentType(header)
+
+	fileId := uuid.NewString()
+	tmp, err := os.CreateTemp(l.root, ".upload-*")
+	if err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), br)
+	if err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to close file: %w", err)
+	}
+
+	info := storage.FileInfo{
+		FileID:       fileId,
+		FileName:     name,
+		FileExt:      ext,
+		FileMimetype: mime,
+		FileSize:     int(size),
+		ETag:         fmt.Sprintf("\"%x\"", hash.Sum(nil)),
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+	}
+	metadata, err := json.Marshal(info)
+	if err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := os.WriteFile(l.metadataPath(fileId), metadata, 0o644); err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.dataPath(fileId)); err != nil {
+		os.Remove(l.metadataPath(fileId))
+		return storage.FileInfo{}, fmt.Errorf("failed to move file: %w", err)
+	}
+
+	return info, nil
+}
+
+func (l *Local) UploadFromBase64(ctx context.Context, base64String, name, ext string) (storage.FileInfo, error) {
+	data, err := base64.StdEncoding.DecodeString(base64String)
+	if err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to decode base64 string: %w", err)
+	}
+
+	return l.Upload(ctx, bytes.NewReader(data), name, ext)
+}
+
+func (l *Local) Download(ctx context.Context, fileId, path string) (*os.File, error) {
+	src, err := l.Stream(ctx, fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file to path %s: %w", path, err)
+	}
+
+	if _, err := io.Copy(file, src); err != nil {
+		return nil, fmt.Errorf("failed to copy file to path %s: %w", path, err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	return file, nil
+}
+
+func (l *Local) DownloadAsBase64(ctx context.Context, fileId string) (string, error) {
+	src, err := l.Stream(ctx, fileId)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, src); err != nil {
+		return "", fmt.Errorf("failed to copy file to buffer: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (l *Local) Stream(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	return l.open(fileId)
+}
+
+func (l *Local) FileInfo(ctx context.Context, fileId string) (storage.FileInfo, error) {
+	if err := validateFileId(fileId); err != nil {
+		return storage.FileInfo{}, err
+	}
+	metadata, err := os.ReadFile(l.metadataPath(fileId))
+	if err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to read metadata: %w", notFound(err))
+	}
+
+	var info storage.FileInfo
+	if err := json.Unmarshal(metadata, &info); err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+
+	return info, nil
+}
+
+// PublicLink returns a link to Handler which is valid until the expiration
+// passes.
+func (l *Local) PublicLink(
+	ctx context.Context,
+	fileId string,
+	publicLinkExpiration time.Duration,
+) (storage.PublicLinkResponse, error) {
+	if _, err := l.FileInfo(ctx, fileId); err != nil {
+		return storage.PublicLinkResponse{}, err
+	}
+	if publicLinkExpiration <= 0 {
+		publicLinkExpiration = storage.DefaultPublicLinkExpiration
+	}
+	expiredAt := time.Now().Add(publicLinkExpiration)
+
+	return storage.PublicLinkResponse{
+		Url:       l.signedURL(fileId, expiredAt),
+		ExpiredAt: expiredAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// Delete removes the file and its metadata. Like S3, deleting a file which
+// doesn't exist isn't an error.
+func (l *Local) Delete(ctx context.Context, fileId string) error {
+	if err := validateFileId(fileId); err != nil {
+		return err
+	}
+	if err := os.Remove(l.dataPath(fileId)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if err := os.Remove(l.metadataPath(fileId)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Local) open(fileId string) (*os.File, error) {
+	if err := validateFileId(fileId); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(l.dataPath(fileId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", notFound(err))
+	}
+
+	return file, nil
+}
+
+func (l *Local) dataPath(fileId string) string {
+	return filepath.Join(l.root, fileId)
+}
+
+func (l *Local) metadataPath(fileId string) string {
+	return filepath.Join(l.root, fileId+metadataExt)
+}
+
+// validateFileId makes sure fileId can't be used to escape the root
+// directory.
+func validateFileId(fileId string) error {
+	if _, err := uuid.Parse(fileId); err != nil {
+		return fmt.Errorf("invalid file id %q: %w", fileId, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// notFound adds storage.ErrNotFound to errors caused by missing files.
+func notFound(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	}
+
+	return err
+}
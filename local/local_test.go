@@ -0,0 +1,149 @@
+package local_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/dptsi/go-storage/local"
+	"github.com/dptsi/go-storage/storagetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleFile = []byte("%PDF-1.4\nsample file content")
+
+func getLocal(t *testing.T, baseURL string) *local.Local {
+	l, err := local.NewLocal(context.Background(), local.Config{
+		Root:       t.TempDir(),
+		BaseURL:    baseURL,
+		SigningKey: []byte("secret"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestUploadFile(t *testing.T) {
+	ctx := context.Background()
+	l := getLocal(t, "")
+
+	info, err := l.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, info.FileID)
+	assert.Equal(t, "sample", info.FileName)
+	assert.Equal(t, ".pdf", info.FileExt)
+	assert.Equal(t, "application/pdf", info.FileMimetype)
+	assert.Equal(t, len(sampleFile), info.FileSize)
+	assert.Equal(t, "\"05567b158f39f9a7c98c0ea9c398ce84\"", info.ETag)
+	assert.NotEmpty(t, info.Timestamp)
+
+	stored, err := l.FileInfo(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, info, stored)
+}
+
+func TestDownloadFile(t *testing.T) {
+	ctx := context.Background()
+	l := getLocal(t, "")
+
+	info, err := l.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := l.Download(ctx, info.FileID, path.Join(t.TempDir(), info.FileID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sampleFile, fileBytes)
+}
+
+func TestRejectPathTraversal(t *testing.T) {
+	ctx := context.Background()
+	l := getLocal(t, "")
+
+	_, err := l.FileInfo(ctx, "../../etc/passwd")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, l.Delete(ctx, "../"+path.Base(os.TempDir())), storage.ErrNotFound)
+}
+
+func TestPublicLink(t *testing.T) {
+	ctx := context.Background()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	l := getLocal(t, server.URL+"/files/")
+	mux.Handle("/files/", http.StripPrefix("/files/", l.Handler()))
+
+	info, err := l.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := l.PublicLink(ctx, info.FileID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, link.ExpiredAt)
+
+	resp, err := http.Get(link.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	assert.Equal(t, info.ETag, resp.Header.Get("ETag"))
+	assert.Equal(t, sampleFile, body)
+
+	tampered, err := http.Get(link.Url + "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.Body.Close()
+	assert.Equal(t, http.StatusForbidden, tampered.StatusCode)
+}
+
+func TestDeleteFile(t *testing.T) {
+	ctx := context.Background()
+	l := getLocal(t, "")
+
+	info, err := l.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Delete(ctx, info.FileID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.FileInfo(ctx, info.FileID)
+	assert.Error(t, err)
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return getLocal(t, "")
+	})
+}
@@ -0,0 +1,268 @@
+// Package memory provides an in-memory storage backend mirroring s3.S3,
+// intended for unit tests which shouldn't depend on real credentials.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/google/uuid"
+)
+
+const DefaultBaseURL = "memory://"
+
+const defaultPageSize = 1000
+
+type Config struct {
+	// BaseURL is prepended to the file ID to build public links.
+	// Defaults to DefaultBaseURL.
+	BaseURL string
+
+	// Now returns the current time. Defaults to time.Now, override it
+	// to get deterministic timestamps.
+	Now func() time.Time
+
+	// NewID generates file IDs. Defaults to uuid.NewString, override it
+	// to get deterministic file IDs.
+	NewID func() string
+}
+
+var (
+	_ storage.Storage     = (*Memory)(nil)
+	_ storage.RangeReader = (*Memory)(nil)
+	_ storage.Lister      = (*Memory)(nil)
+)
+
+type object struct {
+	info storage.FileInfo
+	data []byte
+}
+
+type Memory struct {
+	baseURL string
+	now     func() time.Time
+	newID   func() string
+
+	mu      sync.RWMutex
+	objects map[string]object
+}
+
+func NewMemory(cfg Config) *Memory {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.NewID == nil {
+		cfg.NewID = uuid.NewString
+	}
+
+	return &Memory{
+		baseURL: cfg.BaseURL,
+		now:     cfg.Now,
+		newID:   cfg.NewID,
+		objects: make(map[string]object),
+	}
+}
+
+func (m *Memory) Upload(ctx context.Context, file io.Reader, name, ext string) (storage.FileInfo, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	info := storage.FileInfo{
+		FileID:       m.newID(),
+		FileName:     name,
+		FileExt:      ext,
+		FileMimetype: http.DetectContentType(data),
+		FileSize:     len(data),
+		ETag:         fmt.Sprintf("\"%x\"", md5.Sum(data)),
+		Timestamp:    m.now().UTC().Format(time.RFC3339),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[info.FileID] = object{info: info, data: data}
+
+	return info, nil
+}
+
+func (m *Memory) UploadFromBase64(ctx context.Context, base64String, name, ext string) (storage.FileInfo, error) {
+	data, err := base64.StdEncoding.DecodeString(base64String)
+	if err != nil {
+		return storage.FileInfo{}, fmt.Errorf("failed to decode base64 string: %w", err)
+	}
+
+	return m.Upload(ctx, bytes.NewReader(data), name, ext)
+}
+
+func (m *Memory) Download(ctx context.Context, fileId, path string) (*os.File, error) {
+	obj, err := m.get(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file to path %s: %w", path, err)
+	}
+
+	if _, err := file.Write(obj.data); err != nil {
+		return nil, fmt.Errorf("failed to copy file to path %s: %w", path, err)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	return file, nil
+}
+
+func (m *Memory) DownloadAsBase64(ctx context.Context, fileId string) (string, error) {
+	obj, err := m.get(fileId)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(obj.data), nil
+}
+
+func (m *Memory) Stream(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	obj, err := m.get(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (m *Memory) StreamRange(ctx context.Context, fileId string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid range offset %d", offset)
+	}
+	obj, err := m.get(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	data := obj.data[min(offset, int64(len(obj.data))):]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *Memory) FileInfo(ctx context.Context, fileId string) (storage.FileInfo, error) {
+	obj, err := m.get(fileId)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+
+	return obj.info, nil
+}
+
+func (m *Memory) PublicLink(
+	ctx context.Context,
+	fileId string,
+	publicLinkExpiration time.Duration,
+) (storage.PublicLinkResponse, error) {
+	if _, err := m.get(fileId); err != nil {
+		return storage.PublicLinkResponse{}, err
+	}
+	if publicLinkExpiration <= 0 {
+		publicLinkExpiration = storage.DefaultPublicLinkExpiration
+	}
+
+	return storage.PublicLinkResponse{
+		Url:       m.baseURL + fileId,
+		ExpiredAt: m.now().Add(publicLinkExpiration).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// List returns the files in the order of their ID. The page token is the
+// ID of the last file or prefix of the previous page.
+func (m *Memory) List(ctx context.Context, opts storage.ListOptions) (storage.ListResult, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.objects))
+	for id := range m.objects {
+		if strings.HasPrefix(id, opts.Prefix) {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Strings(ids)
+
+	var result storage.ListResult
+	var last string
+	for _, id := range ids {
+		if id <= opts.PageToken {
+			continue
+		}
+		if opts.Delimiter != "" {
+			if i := strings.Index(id[len(opts.Prefix):], opts.Delimiter); i >= 0 {
+				prefix := id[:len(opts.Prefix)+i+len(opts.Delimiter)]
+				if prefix == last || prefix <= opts.PageToken {
+					continue
+				}
+				if len(result.Files)+len(result.Prefixes) == opts.PageSize {
+					result.NextPageToken = last
+					break
+				}
+				result.Prefixes = append(result.Prefixes, prefix)
+				last = prefix
+				continue
+			}
+		}
+		obj, err := m.get(id)
+		if err != nil {
+			// Deleted while listing.
+			continue
+		}
+		if len(result.Files)+len(result.Prefixes) == opts.PageSize {
+			result.NextPageToken = last
+			break
+		}
+		result.Files = append(result.Files, obj.info)
+		last = id
+	}
+
+	return result, nil
+}
+
+// Delete removes the file. Like S3, deleting a file which doesn't exist
+// isn't an error.
+func (m *Memory) Delete(ctx context.Context, fileId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, fileId)
+
+	return nil
+}
+
+func (m *Memory) get(fileId string) (object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.objects[fileId]
+	if !ok {
+		return object{}, fmt.Errorf("file %s: %w", fileId, storage.ErrNotFound)
+	}
+
+	return obj, nil
+}
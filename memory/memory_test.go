@@ -0,0 +1,128 @@
+package memory_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"path"
+	"testing"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/dptsi/go-storage/memory"
+	"github.com/dptsi/go-storage/storagetest"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleFile = []byte("%PDF-1.4\nsample file content")
+
+var fixedTime = time.Date(2023, 12, 19, 3, 23, 15, 0, time.UTC)
+
+func getMemory() *memory.Memory {
+	return memory.NewMemory(memory.Config{
+		Now:   func() time.Time { return fixedTime },
+		NewID: func() string { return "13c91aa0-94f2-4e37-8167-5d6297a99646" },
+	})
+}
+
+func TestUploadFile(t *testing.T) {
+	ctx := context.Background()
+	m := getMemory()
+
+	info, err := m.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "13c91aa0-94f2-4e37-8167-5d6297a99646", info.FileID)
+	assert.Equal(t, "sample", info.FileName)
+	assert.Equal(t, ".pdf", info.FileExt)
+	assert.Equal(t, "application/pdf", info.FileMimetype)
+	assert.Equal(t, len(sampleFile), info.FileSize)
+	assert.Equal(t, "\"05567b158f39f9a7c98c0ea9c398ce84\"", info.ETag)
+	assert.Equal(t, "2023-12-19T03:23:15Z", info.Timestamp)
+
+	stored, err := m.FileInfo(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, info, stored)
+}
+
+func TestUploadFileFromBase64(t *testing.T) {
+	ctx := context.Background()
+	m := getMemory()
+
+	info, err := m.UploadFromBase64(ctx, base64.StdEncoding.EncodeToString(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64, err := m.DownloadAsBase64(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sampleFile), b64)
+}
+
+func TestDownloadFile(t *testing.T) {
+	ctx := context.Background()
+	m := getMemory()
+
+	info, err := m.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := m.Download(ctx, info.FileID, path.Join(t.TempDir(), info.FileID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sampleFile, fileBytes)
+}
+
+func TestPublicLink(t *testing.T) {
+	ctx := context.Background()
+	m := getMemory()
+
+	info, err := m.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := m.PublicLink(ctx, info.FileID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "memory://"+info.FileID, link.Url)
+	assert.Equal(t, "2023-12-19T04:23:15Z", link.ExpiredAt)
+}
+
+func TestDeleteFile(t *testing.T) {
+	ctx := context.Background()
+	m := getMemory()
+
+	info, err := m.Upload(ctx, bytes.NewReader(sampleFile), "sample", ".pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Delete(ctx, info.FileID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.FileInfo(ctx, info.FileID)
+	assert.Error(t, err)
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return memory.NewMemory(memory.Config{})
+	})
+}
@@ -0,0 +1,87 @@
+package storage
+
+import "mime"
+
+// Keys of the metadata which ObjectMetadata reserves for the file name and
+// extension.
+const (
+	MetadataName = "name"
+	MetadataExt  = "ext"
+)
+
+// UploadOptions sets the headers, metadata and storage class of uploaded
+// files. Empty fields keep the defaults of the backend or of the bucket.
+type UploadOptions struct {
+	// ContentType is used instead of the type detected from the content.
+	ContentType string
+
+	CacheControl string
+
+	// ContentDisposition defaults to an attachment with the file name and
+	// extension.
+	ContentDisposition string
+
+	// ContentEncoding is the encoding the content is already in, such as
+	// "gzip". The content isn't encoded by the upload.
+	ContentEncoding string
+
+	// Metadata is stored next to the file name and extension, which can't
+	// be overridden. S3 lowercases the keys.
+	Metadata map[string]string
+
+	// StorageClass is a storage class of the backend, e.g. "STANDARD_IA" on
+	// S3 or "NEARLINE" on GCS.
+	StorageClass string
+
+	// ACL is a canned ACL of the backend, e.g. "public-read" on S3 or
+	// "publicRead" on GCS. Buckets without object ACLs reject it.
+	ACL string
+}
+
+// Disposition returns ContentDisposition, defaulting to an attachment named
+// name+ext. Files without a name and ext have no default.
+func (o UploadOptions) Disposition(name, ext string) string {
+	if o.ContentDisposition != "" || name+ext == "" {
+		return o.ContentDisposition
+	}
+
+	return AttachmentDisposition(name, ext)
+}
+
+// AttachmentDisposition returns a Content-Disposition which makes downloads,
+// including signed links, save the file under name+ext.
+func AttachmentDisposition(name, ext string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": name + ext})
+}
+
+// ObjectMetadata returns the metadata stored with a file, custom followed by
+// the name and ext, when set, which can't be overridden.
+func ObjectMetadata(name, ext string, custom map[string]string) map[string]string {
+	metadata := make(map[string]string, len(custom)+2)
+	for key, value := range custom {
+		metadata[key] = value
+	}
+	if name != "" || ext != "" {
+		metadata[MetadataName] = name
+		metadata[MetadataExt] = ext
+	}
+
+	return metadata
+}
+
+// CustomMetadata returns the metadata stored by ObjectMetadata without the
+// name and ext, or nil when there is none.
+func CustomMetadata(metadata map[string]string) map[string]string {
+	var custom map[string]string
+	for key, value := range metadata {
+		if key == MetadataName || key == MetadataExt {
+			continue
+		}
+		if custom == nil {
+			custom = make(map[string]string)
+		}
+		custom[key] = value
+	}
+
+	return custom
+}
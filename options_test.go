@@ -0,0 +1,25 @@
+package storage_test
+
+import (
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectMetadata(t *testing.T) {
+	custom := map[string]string{"owner": "alice", "name": "overridden"}
+
+	metadata := storage.ObjectMetadata("report", ".pdf", custom)
+	assert.Equal(t, map[string]string{"owner": "alice", "name": "report", "ext": ".pdf"}, metadata)
+	assert.Equal(t, map[string]string{"owner": "alice"}, storage.CustomMetadata(metadata))
+
+	assert.Equal(t, map[string]string{"owner": "alice"}, storage.ObjectMetadata("", "", map[string]string{"owner": "alice"}))
+	assert.Nil(t, storage.CustomMetadata(storage.ObjectMetadata("report", ".pdf", nil)))
+}
+
+func TestUploadOptionsDisposition(t *testing.T) {
+	assert.Equal(t, `attachment; filename="my report.pdf"`, storage.UploadOptions{}.Disposition("my report", ".pdf"))
+	assert.Equal(t, "inline", storage.UploadOptions{ContentDisposition: "inline"}.Disposition("report", ".pdf"))
+	assert.Empty(t, storage.UploadOptions{}.Disposition("", ""))
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RangeReader is implemented by backends which can read part of a file.
+type RangeReader interface {
+	// StreamRange opens length bytes of the file starting at offset for
+	// reading. A negative length reads until the end of the file, a
+	// negative offset is an error. The caller must close the returned
+	// reader.
+	StreamRange(ctx context.Context, fileId string, offset, length int64) (io.ReadCloser, error)
+}
+
+// maxReadWindow caps the range requested by ObjectReader.Read.
+const maxReadWindow = 16 * 1024 * 1024
+
+// ObjectReader reads a remote file through range requests, so only the
+// parts which are read are downloaded. It implements io.ReaderAt, which is
+// safe for concurrent use, and io.ReadSeekCloser, which is not.
+//
+// Read requests a window of the size of its buffer after a Seek, doubling
+// it for every following window up to 16 MiB. Reading a range through
+// io.LimitReader or io.CopyN, as http.ServeContent does, therefore requests
+// little more than the range.
+type ObjectReader struct {
+	ctx    context.Context
+	r      RangeReader
+	fileId string
+	size   int64
+
+	// offset is the position of Read and body the stream it reads from,
+	// opened on the first Read after a Seek. body ends at end, window is
+	// its length.
+	offset int64
+	end    int64
+	window int64
+	body   io.ReadCloser
+}
+
+// NewObjectReader returns a reader of the file fileId of size bytes. ctx is
+// used for every request made by the reader.
+func NewObjectReader(ctx context.Context, r RangeReader, fileId string, size int64) *ObjectReader {
+	return &ObjectReader{ctx: ctx, r: r, fileId: fileId, size: size}
+}
+
+// Size returns the size of the file.
+func (o *ObjectReader) Size() int64 {
+	return o.size
+}
+
+func (o *ObjectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= o.size {
+		return 0, io.EOF
+	}
+	length := int64(len(p))
+	if remaining := o.size - off; length > remaining {
+		length = remaining
+	}
+	if length == 0 {
+		return 0, nil
+	}
+
+	body, err := o.r.StreamRange(o.ctx, o.fileId, off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p[:length])
+	if err != nil {
+		return n, fmt.Errorf("failed to read range: %w", err)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (o *ObjectReader) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if o.body != nil && o.offset >= o.end {
+		if err := o.closeBody(); err != nil {
+			return 0, err
+		}
+	}
+	if o.body == nil {
+		o.window = min(max(int64(len(p)), 2*o.window), maxReadWindow, o.size-o.offset)
+		body, err := o.r.StreamRange(o.ctx, o.fileId, o.offset, o.window)
+		if err != nil {
+			return 0, err
+		}
+		o.body = body
+		o.end = o.offset + o.window
+	}
+
+	if remaining := o.end - o.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	if errors.Is(err, io.EOF) {
+		switch {
+		case o.offset < o.end:
+			err = io.ErrUnexpectedEOF
+		case o.offset < o.size:
+			// The next Read opens the next window.
+			err = nil
+		}
+	}
+
+	return n, err
+}
+
+func (o *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != o.offset {
+		if err := o.closeBody(); err != nil {
+			return 0, err
+		}
+		o.offset = offset
+		o.window = 0
+	}
+
+	return offset, nil
+}
+
+// Close closes the stream opened by Read. The reader can still be used
+// afterwards.
+func (o *ObjectReader) Close() error {
+	return o.closeBody()
+}
+
+func (o *ObjectReader) closeBody() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+
+	return err
+}
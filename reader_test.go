@@ -0,0 +1,129 @@
+package storage_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// bytesRangeReader serves ranges of data and records the requested lengths
+// and the bytes it returns.
+type bytesRangeReader struct {
+	data    []byte
+	sent    int64
+	lengths []int64
+}
+
+func (b *bytesRangeReader) StreamRange(ctx context.Context, fileId string, offset, length int64) (io.ReadCloser, error) {
+	end := int64(len(b.data))
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+	b.sent += end - offset
+	b.lengths = append(b.lengths, length)
+	return io.NopCloser(bytes.NewReader(b.data[offset:end])), nil
+}
+
+func TestObjectReader(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	r := storage.NewObjectReader(context.Background(), &bytesRangeReader{data: data}, "file", int64(len(data)))
+	defer r.Close()
+
+	p := make([]byte, 4)
+	n, err := r.ReadAt(p, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", string(p[:n]))
+
+	n, err = r.ReadAt(p, 18)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "ij", string(p[:n]))
+
+	_, err = r.ReadAt(p, 20)
+	assert.ErrorIs(t, err, io.EOF)
+
+	pos, err := r.Seek(-5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(15), pos)
+	rest, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "fghij", string(rest))
+
+	_, err = r.Seek(2, io.SeekStart)
+	assert.NoError(t, err)
+	n, err = r.Read(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "2345", string(p[:n]))
+
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+}
+
+func TestObjectReaderRequestsWindows(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	rr := &bytesRangeReader{data: data}
+	r := storage.NewObjectReader(context.Background(), rr, "file", int64(len(data)))
+	defer r.Close()
+
+	// A range read like http.ServeContent does is requested as it is.
+	if _, err := r.Seek(10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, 4))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123", string(b))
+	assert.Equal(t, []int64{4}, rr.lengths)
+
+	// Sequential reads double the window up to the end of the file.
+	rr.lengths = nil
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 8)
+	var read []byte
+	for {
+		n, err := r.Read(p)
+		read = append(read, p[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, data, read)
+	assert.Equal(t, []int64{8, 16, 32, 44}, rr.lengths)
+	assert.Equal(t, int64(4+len(data)), rr.sent)
+}
+
+func TestObjectReaderReadsZipCentralDirectory(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(bytes.Repeat([]byte(name), 64*1024)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := &bytesRangeReader{data: buf.Bytes()}
+	r := storage.NewObjectReader(context.Background(), rr, "file", int64(buf.Len()))
+	zr, err := zip.NewReader(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, zr.File, 2)
+	assert.Equal(t, "b.txt", zr.File[1].Name)
+	// Only the end of the archive is downloaded.
+	assert.Less(t, rr.sent, int64(buf.Len()/10))
+}
@@ -0,0 +1,20 @@
+package storage
+
+type FileInfo struct {
+	FileID       string `json:"file_id"`
+	FileName     string `json:"file_name,omitempty"`
+	FileExt      string `json:"file_ext"`
+	FileMimetype string `json:"file_mimetype"`
+	FileSize     int    `json:"file_size"`
+	ETag         string `json:"etag"`
+	Timestamp    string `json:"timestamp"`
+
+	// Metadata is the custom metadata of UploadOptions.Metadata, on
+	// backends which store it.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type PublicLinkResponse struct {
+	Url       string `json:"url"`
+	ExpiredAt string `json:"expired_at"`
+}
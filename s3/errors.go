@@ -0,0 +1,38 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/smithy-go"
+	storage "github.com/dptsi/go-storage"
+)
+
+// wrapError adds the storage error matching the S3 error code to err, so
+// callers can use errors.Is(err, storage.ErrNotFound).
+func wrapError(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	var target error
+	switch apiErr.ErrorCode() {
+	case "NoSuchKey", "NotFound":
+		target = storage.ErrNotFound
+	case "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken":
+		target = storage.ErrUnauthorized
+	case "AccessDenied", "AllAccessDisabled":
+		target = storage.ErrForbidden
+	case "PreconditionFailed", "OperationAborted":
+		target = storage.ErrConflict
+	case "EntityTooLarge":
+		target = storage.ErrTooLarge
+	case "QuotaExceeded", "ServiceQuotaExceeded":
+		target = storage.ErrQuotaExceeded
+	default:
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", target, err)
+}
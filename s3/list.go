@@ -0,0 +1,56 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	storage "github.com/dptsi/go-storage"
+)
+
+// List returns a page of the files in the bucket with ListObjectsV2. S3
+// doesn't list metadata, so FileName, FileExt and FileMimetype are empty;
+// use FileInfo to get them.
+func (s *S3) List(ctx context.Context, opts storage.ListOptions) (storage.ListResult, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	}
+	if opts.Prefix != "" {
+		input.Prefix = aws.String(opts.Prefix)
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+	if opts.PageToken != "" {
+		input.ContinuationToken = aws.String(opts.PageToken)
+	}
+	if opts.PageSize > 0 {
+		input.MaxKeys = aws.Int32(int32(opts.PageSize))
+	}
+	output, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return storage.ListResult{}, fmt.Errorf("failed to list objects from s3: %w", wrapError(err))
+	}
+
+	result := storage.ListResult{
+		Files: make([]storage.FileInfo, 0, len(output.Contents)),
+	}
+	for _, object := range output.Contents {
+		result.Files = append(result.Files, storage.FileInfo{
+			FileID:    aws.ToString(object.Key),
+			FileSize:  int(aws.ToInt64(object.Size)),
+			ETag:      aws.ToString(object.ETag),
+			Timestamp: aws.ToTime(object.LastModified).UTC().Format(time.RFC3339),
+		})
+	}
+	for _, prefix := range output.CommonPrefixes {
+		result.Prefixes = append(result.Prefixes, aws.ToString(prefix.Prefix))
+	}
+	if aws.ToBool(output.IsTruncated) {
+		result.NextPageToken = aws.ToString(output.NextContinuationToken)
+	}
+
+	return result, nil
+}
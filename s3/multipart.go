@@ -0,0 +1,258 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	storage "github.com/dptsi/go-storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// MinPartSize is the smallest part S3 accepts, except for the last one.
+	MinPartSize = 5 * 1024 * 1024
+	// MaxParts is the largest number of parts of a multipart upload.
+	MaxParts = 10000
+
+	DefaultPartSize           = 16 * 1024 * 1024
+	DefaultConcurrency        = 4
+	DefaultMultipartThreshold = 64 * 1024 * 1024
+)
+
+type UploadLargeOptions struct {
+	storage.UploadOptions
+
+	// PartSize is the size of every part but the last. It is raised to
+	// MinPartSize when smaller and defaults to DefaultPartSize. Objects are
+	// limited to MaxParts parts, so PartSize caps the size of the object.
+	PartSize int64
+
+	// Concurrency is the number of parts uploaded in parallel. Every part
+	// being uploaded is held in memory. Defaults to DefaultConcurrency.
+	Concurrency int
+
+	// Progress is called after every uploaded part with the number of
+	// bytes uploaded so far. Calls are never concurrent.
+	Progress func(uploaded int64)
+}
+
+func (o UploadLargeOptions) withDefaults() UploadLargeOptions {
+	if o.PartSize <= 0 {
+		o.PartSize = DefaultPartSize
+	}
+	if o.PartSize < MinPartSize {
+		o.PartSize = MinPartSize
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+
+	return o
+}
+
+// UploadLarge uploads file as a multipart upload, sending parts in
+// parallel. Every part is sent with its SHA-256 checksum. The multipart
+// upload is aborted when any part or completing it fails, so no incomplete
+// upload is left behind.
+func (s *S3) UploadLarge(
+	ctx context.Context,
+	file io.Reader,
+	name, ext string,
+	opts UploadLargeOptions,
+) (FileInfo, error) {
+	opts = opts.withDefaults()
+	name, err := s.CleanFileName(name)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to sanitize file name: %w", err)
+	}
+	buffered := bufio.NewReader(file)
+	mime := opts.ContentType
+	if mime == "" {
+		mime, err = s.detectMimeType(buffered)
+		if err != nil {
+			return FileInfo{}, fmt.Errorf("failed to detect mime type: %w", err)
+		}
+	}
+
+	metadata := objectMetadata(name, ext, opts.Metadata)
+	fileId := uuid.NewString()
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(s.bucket),
+		Key:                aws.String(fileId),
+		Metadata:           metadata,
+		ContentType:        aws.String(mime),
+		ContentDisposition: optionalString(opts.Disposition(name, ext)),
+		CacheControl:       optionalString(opts.CacheControl),
+		ContentEncoding:    optionalString(opts.ContentEncoding),
+		StorageClass:       types.StorageClass(opts.StorageClass),
+		ACL:                types.ObjectCannedACL(opts.ACL),
+		ChecksumAlgorithm:  types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to create multipart upload to s3: %w", wrapError(err))
+	}
+
+	parts, size, err := s.uploadParts(ctx, buffered, fileId, created.UploadId, opts)
+	if err != nil {
+		return FileInfo{}, s.abortMultipartUpload(ctx, fileId, created.UploadId, err)
+	}
+
+	output, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(fileId),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to complete multipart upload to s3: %w", wrapError(err))
+		return FileInfo{}, s.abortMultipartUpload(ctx, fileId, created.UploadId, err)
+	}
+
+	return FileInfo{
+		FileID:       fileId,
+		FileName:     name,
+		FileExt:      ext,
+		FileMimetype: mime,
+		FileSize:     int(size),
+		ETag:         aws.ToString(output.ETag),
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Metadata:     customMetadata(metadata),
+	}, nil
+}
+
+// abortMultipartUpload aborts the multipart upload after it failed with
+// err, and returns err joined with the error of the abort, if any.
+func (s *S3) abortMultipartUpload(ctx context.Context, fileId string, uploadId *string, err error) error {
+	// Abort even when ctx is done, or the parts are kept and billed.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	if _, abortErr := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(fileId),
+		UploadId: uploadId,
+	}); abortErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to abort multipart upload: %w", abortErr))
+	}
+
+	return err
+}
+
+// uploadParts reads file in parts of opts.PartSize and uploads up to
+// opts.Concurrency of them at once. It returns the completed parts ordered
+// by part number and the total size.
+func (s *S3) uploadParts(
+	ctx context.Context,
+	file io.Reader,
+	fileId string,
+	uploadId *string,
+	opts UploadLargeOptions,
+) ([]types.CompletedPart, int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		parts     []types.CompletedPart
+		uploaded  int64
+		uploadErr error
+		size      int64
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if uploadErr == nil {
+			uploadErr = err
+			cancel()
+		}
+	}
+	sem := make(chan struct{}, opts.Concurrency)
+
+	for partNumber := int32(1); ; partNumber++ {
+		if partNumber > MaxParts {
+			fail(fmt.Errorf("file exceeds %d parts of %d bytes", MaxParts, opts.PartSize))
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		data := make([]byte, opts.PartSize)
+		n, readErr := io.ReadFull(file, data)
+		last := errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF)
+		if readErr != nil && !last {
+			<-sem
+			fail(fmt.Errorf("failed to read file: %w", readErr))
+			break
+		}
+		// An empty part is only needed when the whole file is empty.
+		if n == 0 && partNumber > 1 {
+			<-sem
+			break
+		}
+		size += int64(n)
+
+		wg.Add(1)
+		go func(partNumber int32, data []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			output, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:            aws.String(s.bucket),
+				Key:               aws.String(fileId),
+				UploadId:          uploadId,
+				PartNumber:        aws.Int32(partNumber),
+				Body:              bytes.NewReader(data),
+				ContentLength:     aws.Int64(int64(len(data))),
+				ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+			})
+			if err != nil {
+				fail(fmt.Errorf("failed to upload part %d to s3: %w", partNumber, wrapError(err)))
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			parts = append(parts, types.CompletedPart{
+				ETag:           output.ETag,
+				PartNumber:     aws.Int32(partNumber),
+				ChecksumSHA256: output.ChecksumSHA256,
+			})
+			uploaded += int64(len(data))
+			if opts.Progress != nil {
+				opts.Progress(uploaded)
+			}
+		}(partNumber, data[:n])
+
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+
+	if uploadErr != nil {
+		return nil, 0, uploadErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+
+	return parts, size, nil
+}
@@ -0,0 +1,195 @@
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	storage "github.com/dptsi/go-storage"
+	"github.com/google/uuid"
+)
+
+type PresignUploadOptions struct {
+	// Name and Ext are stored as the file name and extension, like the
+	// arguments of Upload.
+	Name string
+	Ext  string
+
+	// ContentType is the content type the client must upload the file
+	// with. It is only enforced by POST uploads, where a value ending with a
+	// slash, such as "image/", allows any content type with that prefix.
+	// Anything is allowed when empty.
+	ContentType string
+
+	// MinSize and MaxSize limit the size of a POST upload in bytes. MaxSize
+	// isn't limited when zero. They don't apply to PUT uploads.
+	MinSize int64
+	MaxSize int64
+
+	// Expiration defaults to DefaultPublicLinkExpiration.
+	Expiration time.Duration
+}
+
+// PresignUpload returns a URL the client can PUT the file to directly,
+// without sending it through this service. The file ID is allocated
+// up front; the file exists once the client finished uploading. The
+// client must send Headers with the request.
+func (s *S3) PresignUpload(ctx context.Context, opts PresignUploadOptions) (PresignedUpload, error) {
+	name, err := s.CleanFileName(opts.Name)
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to sanitize file name: %w", err)
+	}
+	if opts.Expiration <= 0 {
+		opts.Expiration = DefaultPublicLinkExpiration
+	}
+
+	fileId := uuid.NewString()
+	input := &s3.PutObjectInput{
+		Bucket:             aws.String(s.bucket),
+		Key:                aws.String(fileId),
+		Metadata:           objectMetadata(name, opts.Ext, nil),
+		ContentDisposition: aws.String(storage.AttachmentDisposition(name, opts.Ext)),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	request, err := s.presignClient.PresignPutObject(ctx, input, func(o *s3.PresignOptions) {
+		o.Expires = opts.Expiration
+	})
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to presign object upload to s3: %w", err)
+	}
+
+	headers := make(map[string]string)
+	for key, values := range request.SignedHeader {
+		if !strings.EqualFold(key, "Host") {
+			headers[key] = strings.Join(values, ",")
+		}
+	}
+	// Content-Type isn't signed, so it's expected but not enforced.
+	if opts.ContentType != "" {
+		headers["Content-Type"] = opts.ContentType
+	}
+
+	return PresignedUpload{
+		FileID:    fileId,
+		Url:       request.URL,
+		Method:    http.MethodPut,
+		Headers:   headers,
+		ExpiredAt: time.Now().Add(opts.Expiration).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// PresignUploadPost returns a POST policy the client can upload the file
+// with from an HTML form, which unlike PresignUpload can limit the size
+// and content type of the file. The client sends Fields as form fields
+// followed by the file in a field named "file".
+func (s *S3) PresignUploadPost(ctx context.Context, opts PresignUploadOptions) (PresignedUpload, error) {
+	name, err := s.CleanFileName(opts.Name)
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to sanitize file name: %w", err)
+	}
+	if opts.Expiration <= 0 {
+		opts.Expiration = DefaultPublicLinkExpiration
+	}
+
+	options := s.client.Options()
+	creds, err := options.Credentials.Retrieve(ctx)
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to retrieve aws credentials: %w", err)
+	}
+
+	fileId := uuid.NewString()
+	// The bucket URL is the URL of any of its objects without the key, so
+	// it follows the endpoint and addressing style of the client.
+	objectRequest, err := s.presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(fileId),
+	})
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to presign object from s3: %w", err)
+	}
+	bucketUrl, err := url.Parse(objectRequest.URL)
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to parse bucket url: %w", err)
+	}
+	bucketUrl.Path = strings.TrimSuffix(bucketUrl.Path, fileId)
+	bucketUrl.RawPath = ""
+	bucketUrl.RawQuery = ""
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	fields := map[string]string{
+		"key":                 fileId,
+		"Content-Disposition": storage.AttachmentDisposition(name, opts.Ext),
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    fmt.Sprintf("%s/%s/%s/s3/aws4_request", creds.AccessKeyID, date, options.Region),
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+	}
+	for key, value := range objectMetadata(name, opts.Ext, nil) {
+		fields[http.CanonicalHeaderKey("x-amz-meta-"+key)] = value
+	}
+	if creds.SessionToken != "" {
+		fields["X-Amz-Security-Token"] = creds.SessionToken
+	}
+
+	conditions := []any{map[string]string{"bucket": s.bucket}}
+	for key, value := range fields {
+		conditions = append(conditions, map[string]string{key: value})
+	}
+	if strings.HasSuffix(opts.ContentType, "/") {
+		conditions = append(conditions, []string{"starts-with", "$Content-Type", opts.ContentType})
+	} else if opts.ContentType != "" {
+		fields["Content-Type"] = opts.ContentType
+		conditions = append(conditions, map[string]string{"Content-Type": opts.ContentType})
+	}
+	if opts.MinSize > 0 || opts.MaxSize > 0 {
+		maxSize := opts.MaxSize
+		if maxSize <= 0 {
+			// The largest object S3 accepts.
+			maxSize = 5 << 40
+		}
+		conditions = append(conditions, []any{"content-length-range", opts.MinSize, maxSize})
+	}
+
+	expiredAt := now.Add(opts.Expiration)
+	policy, err := json.Marshal(map[string]any{
+		"expiration": expiredAt.Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to encode post policy: %w", err)
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+	fields["X-Amz-Signature"] = signPolicy(creds.SecretAccessKey, date, options.Region, fields["policy"])
+
+	return PresignedUpload{
+		FileID:    fileId,
+		Url:       bucketUrl.String(),
+		Method:    http.MethodPost,
+		Fields:    fields,
+		ExpiredAt: expiredAt.Format(time.RFC3339),
+	}, nil
+}
+
+// signPolicy returns the Signature Version 4 signature of a POST policy.
+func signPolicy(secretAccessKey, date, region, policy string) string {
+	key := []byte("AWS4" + secretAccessKey)
+	for _, data := range []string{date, region, "s3", "aws4_request", policy} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		key = mac.Sum(nil)
+	}
+
+	return hex.EncodeToString(key)
+}
@@ -2,14 +2,33 @@ package s3
 
 type FileInfo struct {
 	FileID       string `json:"file_id"`
+	FileName     string `json:"file_name"`
 	FileExt      string `json:"file_ext"`
 	FileMimetype string `json:"file_mimetype"`
 	FileSize     int    `json:"file_size"`
 	ETag         string `json:"etag"`
 	Timestamp    string `json:"timestamp"`
+
+	// Metadata is the custom metadata of storage.UploadOptions.Metadata,
+	// with the keys in lower case.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type PublicLinkResponse struct {
 	Url       string `json:"url"`
 	ExpiredAt string `json:"expired_at"`
 }
+
+type PresignedUpload struct {
+	FileID string `json:"file_id"`
+	Url    string `json:"url"`
+	Method string `json:"method"`
+
+	// Headers must be sent with a PUT upload.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Fields are the form fields of a POST upload.
+	Fields map[string]string `json:"fields,omitempty"`
+
+	ExpiredAt string `json:"expired_at"`
+}
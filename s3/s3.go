@@ -1,6 +1,7 @@
 package s3
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -15,82 +16,172 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	storage "github.com/dptsi/go-storage"
 	"github.com/google/uuid"
 )
 
 const DefaultPublicLinkExpiration = 30 * time.Minute
 
 type Config struct {
-	Region          string
-	Bucket          string
+	Region string
+	Bucket string
+
+	// AccessKeyId, SecretAccessKey and SessionToken are static credentials.
+	// When AccessKeyId is empty the default credential chain is used, e.g.
+	// environment variables, shared config, IRSA or the instance IAM role.
 	AccessKeyId     string
 	SecretAccessKey string
+	SessionToken    string
+
+	// Endpoint is the URL of an S3-compatible service, such as MinIO, Ceph
+	// or Cloudflare R2, e.g. "http://localhost:9000". Presigned links use it
+	// too. Defaults to AWS.
+	Endpoint string
+
+	// UsePathStyle addresses buckets as "endpoint/bucket" instead of
+	// "bucket.endpoint", which most S3-compatible services need.
+	UsePathStyle bool
+
+	// MultipartThreshold is the size above which Upload switches to a
+	// multipart upload. Defaults to DefaultMultipartThreshold.
+	MultipartThreshold int64
+
+	// FileNamePolicy sanitizes the names of uploaded files. Defaults to
+	// storage.DefaultFileNamePolicy().
+	FileNamePolicy *storage.FileNamePolicy
 }
 
 type S3 struct {
-	bucket        string
-	client        *s3.Client
-	presignClient *s3.PresignClient
+	bucket             string
+	client             *s3.Client
+	presignClient      *s3.PresignClient
+	multipartThreshold int64
+	fileNamePolicy     *storage.FileNamePolicy
 }
 
 func NewS3(ctx context.Context, cfg Config) (*S3, error) {
-	awsCfg, err := config.LoadDefaultConfig(
-		context.TODO(),
-		config.WithRegion(cfg.Region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+	opts := []func(*config.LoadOptions) error{config.WithRegion(cfg.Region)}
+	if cfg.AccessKeyId != "" {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
 			cfg.AccessKeyId,
 			cfg.SecretAccessKey,
-			"",
-		)),
-	)
+			cfg.SessionToken,
+		)))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load aws config: %w", err)
 	}
-	client := s3.NewFromConfig(awsCfg)
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+	multipartThreshold := cfg.MultipartThreshold
+	if multipartThreshold <= 0 {
+		multipartThreshold = DefaultMultipartThreshold
+	}
+	fileNamePolicy := cfg.FileNamePolicy
+	if fileNamePolicy == nil {
+		fileNamePolicy = storage.DefaultFileNamePolicy()
+	}
 
 	return &S3{
-		bucket:        cfg.Bucket,
-		client:        client,
-		presignClient: s3.NewPresignClient(client),
+		bucket:             cfg.Bucket,
+		client:             client,
+		presignClient:      s3.NewPresignClient(client),
+		multipartThreshold: multipartThreshold,
+		fileNamePolicy:     fileNamePolicy,
 	}, nil
 }
 
-func (s *S3) Upload(ctx context.Context, file io.ReadSeeker, name, ext string) (FileInfo, error) {
-	if _, err := file.Seek(0, 0); err != nil {
-		return FileInfo{}, fmt.Errorf("failed to seek file: %w", err)
-	}
-	mime, err := s.detectMimeType(file)
+// Upload stores file under a new file ID. Seekable files are uploaded from
+// the start. The size of other readers is found by buffering them up to
+// Config.MultipartThreshold in memory, larger files are sent with
+// UploadLarge, which holds DefaultConcurrency parts in memory.
+func (s *S3) Upload(ctx context.Context, file io.Reader, name, ext string) (FileInfo, error) {
+	return s.UploadWithOptions(ctx, file, name, ext, storage.UploadOptions{})
+}
+
+// UploadWithOptions is Upload with control over the headers, metadata and
+// storage class of the object.
+func (s *S3) UploadWithOptions(ctx context.Context, file io.Reader, name, ext string, opts storage.UploadOptions) (FileInfo, error) {
+	name, err := s.CleanFileName(name)
 	if err != nil {
-		return FileInfo{}, fmt.Errorf("failed to detect mime type: %w", err)
+		return FileInfo{}, fmt.Errorf("failed to sanitize file name: %w", err)
+	}
+	var body io.ReadSeeker
+	var size int64
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return FileInfo{}, fmt.Errorf("failed to seek file: %w", err)
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return FileInfo{}, fmt.Errorf("failed to seek file: %w", err)
+		}
+		body, size = seeker, end
+	} else {
+		head, err := io.ReadAll(io.LimitReader(file, s.multipartThreshold+1))
+		if err != nil {
+			return FileInfo{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		size = int64(len(head))
+		if size <= s.multipartThreshold {
+			body = bytes.NewReader(head)
+		} else {
+			file = io.MultiReader(bytes.NewReader(head), file)
+		}
+	}
+	if size > s.multipartThreshold {
+		partSize := int64(DefaultPartSize)
+		if size/MaxParts >= partSize {
+			partSize = size/MaxParts + 1
+		}
+		return s.UploadLarge(ctx, file, name, ext, UploadLargeOptions{PartSize: partSize, UploadOptions: opts})
 	}
 
-	if _, err := file.Seek(0, 0); err != nil {
-		return FileInfo{}, fmt.Errorf("failed to seek file: %w", err)
+	mime := opts.ContentType
+	if mime == "" {
+		mime, err = s.detectMimeType(bufio.NewReader(body))
+		if err != nil {
+			return FileInfo{}, fmt.Errorf("failed to detect mime type: %w", err)
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return FileInfo{}, fmt.Errorf("failed to seek file: %w", err)
+		}
 	}
+
+	metadata := objectMetadata(name, ext, opts.Metadata)
 	fileId := uuid.NewString()
 	output, err := s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: &s.bucket,
-		Key:    &fileId,
-		Body:   file,
-		Metadata: map[string]string{
-			"ext": ext,
-		},
-		ContentType: aws.String(mime),
+		Bucket:             &s.bucket,
+		Key:                &fileId,
+		Body:               body,
+		ContentLength:      aws.Int64(size),
+		Metadata:           metadata,
+		ContentType:        aws.String(mime),
+		ContentDisposition: optionalString(opts.Disposition(name, ext)),
+		CacheControl:       optionalString(opts.CacheControl),
+		ContentEncoding:    optionalString(opts.ContentEncoding),
+		StorageClass:       types.StorageClass(opts.StorageClass),
+		ACL:                types.ObjectCannedACL(opts.ACL),
 	})
 	if err != nil {
-		return FileInfo{}, fmt.Errorf("failed to put object to s3: %w", err)
-	}
-	if _, err := file.Seek(0, 0); err != nil {
-		return FileInfo{}, fmt.Errorf("failed to seek file: %w", err)
+		return FileInfo{}, fmt.Errorf("failed to put object to s3: %w", wrapError(err))
 	}
 
 	return FileInfo{
 		FileID:       fileId,
+		FileName:     name,
 		FileExt:      ext,
 		FileMimetype: mime,
-		FileSize:     s.getSize(file),
+		FileSize:     int(size),
 		ETag:         *output.ETag,
 		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Metadata:     customMetadata(metadata),
 	}, nil
 }
 
@@ -109,7 +200,7 @@ func (s *S3) Download(ctx context.Context, fileId, path string) (*os.File, error
 		Key:    aws.String(fileId),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get object from s3: %w", err)
+		return nil, fmt.Errorf("failed to get object from s3: %w", wrapError(err))
 	}
 	defer output.Body.Close()
 
@@ -128,13 +219,60 @@ func (s *S3) Download(ctx context.Context, fileId, path string) (*os.File, error
 	return file, nil
 }
 
+func (s *S3) Stream(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(fileId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from s3: %w", wrapError(err))
+	}
+
+	return output.Body, nil
+}
+
+// StreamRange implements storage.RangeReader.
+func (s *S3) StreamRange(ctx context.Context, fileId string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid range offset %d", offset)
+	}
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(fileId),
+		Range:  aws.String(byteRange),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object range from s3: %w", wrapError(err))
+	}
+
+	return output.Body, nil
+}
+
+// Open returns a storage.ObjectReader of the file.
+func (s *S3) Open(ctx context.Context, fileId string) (*storage.ObjectReader, error) {
+	info, err := s.FileInfo(ctx, fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewObjectReader(ctx, s, fileId, int64(info.FileSize)), nil
+}
+
 func (s *S3) DownloadAsBase64(ctx context.Context, fileId string) (string, error) {
 	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(fileId),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to get object from s3: %w", err)
+		return "", fmt.Errorf("failed to get object from s3: %w", wrapError(err))
 	}
 	defer output.Body.Close()
 
@@ -152,7 +290,7 @@ func (s *S3) FileInfo(ctx context.Context, fileId string) (FileInfo, error) {
 		Key:    aws.String(fileId),
 	})
 	if err != nil {
-		return FileInfo{}, fmt.Errorf("failed to head object from s3: %w", err)
+		return FileInfo{}, fmt.Errorf("failed to head object from s3: %w", wrapError(err))
 	}
 
 	metadata := output.Metadata
@@ -162,11 +300,13 @@ func (s *S3) FileInfo(ctx context.Context, fileId string) (FileInfo, error) {
 
 	return FileInfo{
 		FileID:       fileId,
+		FileName:     metadataFileName(metadata),
 		FileExt:      metadata["ext"],
-		FileMimetype: *output.ContentType,
-		FileSize:     int(*output.ContentLength),
-		ETag:         *output.ETag,
-		Timestamp:    output.LastModified.UTC().Format(time.RFC3339),
+		FileMimetype: aws.ToString(output.ContentType),
+		FileSize:     int(aws.ToInt64(output.ContentLength)),
+		ETag:         aws.ToString(output.ETag),
+		Timestamp:    aws.ToTime(output.LastModified).UTC().Format(time.RFC3339),
+		Metadata:     customMetadata(metadata),
 	}, nil
 }
 
@@ -178,6 +318,8 @@ func (s *S3) PublicLink(
 	if publicLinkExpiration <= 0 {
 		publicLinkExpiration = DefaultPublicLinkExpiration
 	}
+	// The presign client shares the endpoint and addressing style of the
+	// client, so links point at S3-compatible services too.
 	request, err := s.presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(fileId),
@@ -199,19 +341,33 @@ func (s *S3) Delete(ctx context.Context, fileId string) error {
 		Key:    &fileId,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete object from s3: %w", err)
+		return fmt.Errorf("failed to delete object from s3: %w", wrapError(err))
 	}
 
 	return nil
 }
 
+// CleanFileName applies Config.FileNamePolicy to nameWithoutExt. Names
+// containing a path return storage.ErrInvalidFileName.
+func (s *S3) CleanFileName(nameWithoutExt string) (string, error) {
+	return s.fileNamePolicy.Sanitize(nameWithoutExt)
+}
+
+// pathSeparators are replaced in names given to SanitizeFileName.
+var pathSeparators = strings.NewReplacer("/", "_", "\\", "_", "\x00", "_")
+
+// SanitizeFileName applies Config.FileNamePolicy to nameWithoutExt, like
+// CleanFileName, but sanitizes names containing a path instead of rejecting
+// them.
+//
+// Deprecated: Use CleanFileName, which reports names containing a path.
 func (s *S3) SanitizeFileName(nameWithoutExt string) string {
-	nameWithoutExt = strings.ReplaceAll(nameWithoutExt, "/[^a-zA-Z0-9-]+/", "_")
-	if nameWithoutExt == "" {
-		nameWithoutExt = fmt.Sprintf("undefined_%d", time.Now().Unix())
+	name, err := s.CleanFileName(nameWithoutExt)
+	if err != nil {
+		name, _ = s.CleanFileName(strings.Trim(pathSeparators.Replace(nameWithoutExt), "."))
 	}
 
-	return nameWithoutExt
+	return name
 }
 
 func (s *S3) GetFileExt(nameWithExt string) string {
@@ -1,230 +1,690 @@
 package s3_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
-	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	storage "github.com/dptsi/go-storage"
 	"github.com/dptsi/go-storage/s3"
+	"github.com/dptsi/go-storage/storagetest"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
 	"github.com/stretchr/testify/assert"
 )
 
-func getS3(ctx context.Context) *s3.S3 {
-	s3, err := s3.NewS3(ctx, s3.Config{
-		Region:          os.Getenv("S3_REGION"),
-		Bucket:          os.Getenv("S3_BUCKET"),
-		AccessKeyId:     os.Getenv("S3_ACCESS_KEY_ID"),
-		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
-	})
-	if err != nil {
-		panic(err)
-	}
-	return s3
+const testBucket = "test-bucket"
+
+// newFakeS3 starts an in-process gofakes3 server with an empty test bucket,
+// so the tests run offline, and returns its URL.
+func newFakeS3(t *testing.T) string {
+	server := httptest.NewServer(newFakeS3Handler(t))
+	t.Cleanup(server.Close)
+
+	return server.URL
 }
 
-const sampleFileUrl = "https://placehold.co/100x100/jpg"
+func newFakeS3Handler(t *testing.T) http.Handler {
+	backend := s3mem.New()
+	if err := backend.CreateBucket(testBucket); err != nil {
+		t.Fatal(err)
+	}
 
-var preuploadedFileInfo = s3.FileInfo{
-	FileID:       "13c91aa0-94f2-4e37-8167-5d6297a99646",
-	FileExt:      "",
-	FileMimetype: "image/jpeg",
-	FileSize:     750,
-	ETag:         "\"cf4cb127768fbc6ba1484fa6270d5c54\"",
-	Timestamp:    "2023-12-19T03:23:15Z",
+	return gofakes3.New(backend, gofakes3.WithLogger(gofakes3.DiscardLog())).Server()
 }
 
-const preuploadedFileSha256 = "263cef32b161ff9365635244b899dd917b3f93b3d2ee322f0094c2b7f336a824"
+// getS3 returns an S3 backed by a new gofakes3 server.
+func getS3(t *testing.T) *s3.S3 {
+	return getS3WithEndpoint(t, newFakeS3(t))
+}
 
-func downloadSampleFile(ctx context.Context) (*os.File, error) {
-	file, err := os.CreateTemp("", "sample")
+func getS3WithEndpoint(t *testing.T, endpoint string) *s3.S3 {
+	s3Client, err := s3.NewS3(context.Background(), s3.Config{
+		Region:          "us-east-1",
+		Bucket:          testBucket,
+		AccessKeyId:     "access-key",
+		SecretAccessKey: "secret-key",
+		Endpoint:        endpoint,
+		UsePathStyle:    true,
+	})
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
 	}
+	return s3Client
+}
 
-	resp, err := http.Get(sampleFileUrl)
-	if err != nil {
-		return nil, err
+// sampleImage returns a generated 100x100 JPEG.
+func sampleImage(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for x := 0; x < 100; x++ {
+		for y := 0; y < 100; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
 	}
-	defer resp.Body.Close()
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
 
-	_, err = io.Copy(file, resp.Body)
+func uploadSample(t *testing.T, s3Client *s3.S3) (s3.FileInfo, []byte) {
+	data := sampleImage(t)
+	info, err := s3Client.Upload(context.Background(), bytes.NewReader(data), "sample", ".jpg")
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
 	}
-
-	return file, nil
+	return info, data
 }
 
-func cleanupFile(t *testing.T, file *os.File) {
-	name := file.Name()
-	if err := file.Close(); err != nil {
-		t.Fatalf("failed to close file (%s): %v", name, err)
-	}
-	if err := os.Remove(name); err != nil {
-		t.Fatalf("failed to remove file (%s): %v", name, err)
-	}
+func sha256Hex(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
 }
 
 func TestUploadFile(t *testing.T) {
 	ctx := context.Background()
-	s3 := getS3(ctx)
+	s3Client := getS3(t)
 
-	file, err := downloadSampleFile(ctx)
+	file, err := os.CreateTemp(t.TempDir(), "sample*.jpg")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer cleanupFile(t, file)
+	defer file.Close()
+	data := sampleImage(t)
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
 
-	fileName := file.Name()
-	fileExt := path.Ext(fileName)
-	fileName = strings.TrimSuffix(fileName, fileExt)
-	info, err := s3.Upload(ctx, file, fileName, fileExt)
+	// Seekable files are uploaded from the start, whatever their offset.
+	info, err := s3Client.Upload(ctx, file, "sample", ".jpg")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.Log(info)
 	assert.NotEmpty(t, info.FileID)
-	assert.Equal(t, fileExt, info.FileExt)
-	assert.NotEmpty(t, info.FileMimetype)
-	assert.NotEmpty(t, info.FileSize)
+	assert.Equal(t, "sample", info.FileName)
+	assert.Equal(t, ".jpg", info.FileExt)
+	assert.Equal(t, "image/jpeg", info.FileMimetype)
+	assert.Equal(t, len(data), info.FileSize)
 	assert.NotEmpty(t, info.ETag)
 	assert.NotEmpty(t, info.Timestamp)
 }
 
 func TestUploadFileFromBase64(t *testing.T) {
 	ctx := context.Background()
-	s3 := getS3(ctx)
+	s3Client := getS3(t)
 
-	file, err := downloadSampleFile(ctx)
+	data := sampleImage(t)
+	info, err := s3Client.UploadFromBase64(ctx, base64.StdEncoding.EncodeToString(data), "sample", ".jpg")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer cleanupFile(t, file)
 
-	fileName := file.Name()
-	fileExt := path.Ext(fileName)
-	fileName = strings.TrimSuffix(fileName, fileExt)
+	assert.NotEmpty(t, info.FileID)
+	assert.Equal(t, ".jpg", info.FileExt)
+	assert.Equal(t, "image/jpeg", info.FileMimetype)
+	assert.Equal(t, len(data), info.FileSize)
+	assert.NotEmpty(t, info.ETag)
+
+	_, err = s3Client.UploadFromBase64(ctx, "not base64!", "sample", ".jpg")
+	assert.Error(t, err)
+}
 
-	if _, err := file.Seek(0, 0); err != nil {
+func TestUploadFileFromReader(t *testing.T) {
+	ctx := context.Background()
+	s3Client := getS3(t)
+
+	// A pipe is neither seekable nor sized, like an HTTP request body.
+	data := []byte("%PDF-1.4\nsample file content")
+	r, w := io.Pipe()
+	go func() {
+		_, err := w.Write(data)
+		w.CloseWithError(err)
+	}()
+	info, err := s3Client.Upload(ctx, r, "sample", ".pdf")
+	if err != nil {
 		t.Fatal(err)
 	}
-	fileBytes, err := io.ReadAll(file)
+
+	assert.Equal(t, "sample", info.FileName)
+	assert.Equal(t, "application/pdf", info.FileMimetype)
+	assert.Equal(t, len(data), info.FileSize)
+
+	info, err = s3Client.FileInfo(ctx, info.FileID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	info, err := s3.UploadFromBase64(
-		ctx,
-		base64.StdEncoding.Strict().EncodeToString(fileBytes),
-		fileName,
-		fileExt,
-	)
+	assert.Equal(t, "sample", info.FileName)
+	assert.Equal(t, ".pdf", info.FileExt)
+}
+
+func TestUploadLargeFile(t *testing.T) {
+	ctx := context.Background()
+	s3Client := getS3(t)
+
+	// Three parts, the last one smaller than the others.
+	data := bytes.Repeat([]byte("0123456789abcdef"), (2*s3.MinPartSize+1024)/16)
+	var progress []int64
+	info, err := s3Client.UploadLarge(ctx, bytes.NewReader(data), "large", ".bin", s3.UploadLargeOptions{
+		PartSize:    s3.MinPartSize,
+		Concurrency: 2,
+		Progress: func(uploaded int64) {
+			progress = append(progress, uploaded)
+		},
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.Log(info)
-	assert.NotEmpty(t, info.FileID)
-	assert.Equal(t, fileExt, info.FileExt)
-	assert.NotEmpty(t, info.FileMimetype)
-	assert.NotEmpty(t, info.FileSize)
+	assert.Equal(t, len(data), info.FileSize)
 	assert.NotEmpty(t, info.ETag)
-	assert.NotEmpty(t, info.Timestamp)
+	assert.Len(t, progress, 3)
+	assert.Equal(t, int64(len(data)), progress[len(progress)-1])
+
+	b64, err := s3Client.DownloadAsBase64(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileBytes, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sha256Hex(data), sha256Hex(fileBytes))
+}
+
+// failingReader returns n bytes and then err.
+type failingReader struct {
+	n   int
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, r.err
+	}
+	n := min(len(p), r.n)
+	r.n -= n
+	return n, nil
+}
+
+func TestUploadLargeFileAbortsOnError(t *testing.T) {
+	errRead := errors.New("read failed")
+	for name, tt := range map[string]struct {
+		file         io.Reader
+		cancel       bool
+		failComplete bool
+		wantErr      error
+		wantCode     string
+	}{
+		"read error":      {file: &failingReader{n: s3.MinPartSize + 1024, err: errRead}, wantErr: errRead},
+		"cancelled":       {file: bytes.NewReader(make([]byte, 3*s3.MinPartSize)), cancel: true, wantErr: context.Canceled},
+		"complete failed": {file: bytes.NewReader(make([]byte, 2*s3.MinPartSize)), failComplete: true, wantCode: "InvalidPart"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeS3Handler(t)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// CompleteMultipartUpload is the only POST with an upload ID.
+				if tt.failComplete && r.Method == http.MethodPost && r.URL.Query().Has("uploadId") {
+					w.WriteHeader(http.StatusBadRequest)
+					io.WriteString(w, `<Error><Code>InvalidPart</Code><Message>invalid part</Message></Error>`)
+					return
+				}
+				fake.ServeHTTP(w, r)
+			}))
+			t.Cleanup(server.Close)
+			endpoint := server.URL
+			s3Client := getS3WithEndpoint(t, endpoint)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			_, err := s3Client.UploadLarge(ctx, tt.file, "large", ".bin", s3.UploadLargeOptions{
+				PartSize:    s3.MinPartSize,
+				Concurrency: 1,
+				Progress: func(uploaded int64) {
+					if tt.cancel {
+						cancel()
+					}
+				},
+			})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			var apiErr smithy.APIError
+			if tt.wantCode != "" && assert.ErrorAs(t, err, &apiErr) {
+				assert.Equal(t, tt.wantCode, apiErr.ErrorCode())
+			}
+
+			client := awss3.New(awss3.Options{
+				Region:       "us-east-1",
+				BaseEndpoint: aws.String(endpoint),
+				UsePathStyle: true,
+				Credentials:  credentials.NewStaticCredentialsProvider("access-key", "secret-key", ""),
+			})
+			uploads, err := client.ListMultipartUploads(context.Background(), &awss3.ListMultipartUploadsInput{
+				Bucket: aws.String(testBucket),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Empty(t, uploads.Uploads, "pending multipart uploads")
+		})
+	}
 }
 
 func TestFileInfo(t *testing.T) {
 	ctx := context.Background()
-	s3 := getS3(ctx)
+	s3Client := getS3(t)
+	uploaded, data := uploadSample(t, s3Client)
 
-	info, err := s3.FileInfo(ctx, preuploadedFileInfo.FileID)
+	info, err := s3Client.FileInfo(ctx, uploaded.FileID)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.Log(info)
-	assert.Equal(t, preuploadedFileInfo, info)
+	assert.Equal(t, uploaded.FileID, info.FileID)
+	assert.Equal(t, "sample", info.FileName)
+	assert.Equal(t, ".jpg", info.FileExt)
+	assert.Equal(t, "image/jpeg", info.FileMimetype)
+	assert.Equal(t, len(data), info.FileSize)
+	assert.Equal(t, uploaded.ETag, info.ETag)
+	assert.NotEmpty(t, info.Timestamp)
 }
 
 func TestDownloadFile(t *testing.T) {
-	tmpDir, err := os.MkdirTemp("", "sample")
+	ctx := context.Background()
+	s3Client := getS3(t)
+	info, data := uploadSample(t, s3Client)
+
+	file, err := s3Client.Download(ctx, info.FileID, path.Join(t.TempDir(), info.FileID))
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer file.Close()
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sha256Hex(data), sha256Hex(fileBytes))
+}
+
+func TestDownloadFileAsBase64(t *testing.T) {
 	ctx := context.Background()
-	s3 := getS3(ctx)
+	s3Client := getS3(t)
+	info, data := uploadSample(t, s3Client)
 
-	filePath := path.Join(tmpDir, preuploadedFileInfo.FileID)
-	file, err := s3.Download(ctx, preuploadedFileInfo.FileID, filePath)
+	b64, err := s3Client.DownloadAsBase64(ctx, info.FileID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer cleanupFile(t, file)
 
-	t.Log(file.Name())
-	fileBytes, err := io.ReadAll(file)
+	fileBytes, err := base64.StdEncoding.Strict().DecodeString(b64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sha256Hex(data), sha256Hex(fileBytes))
+}
+
+func TestStreamRange(t *testing.T) {
+	ctx := context.Background()
+	s3Client := getS3(t)
+	info, data := uploadSample(t, s3Client)
+
+	r, err := s3Client.StreamRange(ctx, info.FileID, 100, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
+	assert.Equal(t, data[100:150], got)
 
-	assert.Equal(t, preuploadedFileSha256, fmt.Sprintf("%x", sha256.Sum256(fileBytes)))
+	r, err = s3Client.StreamRange(ctx, info.FileID, int64(len(data)-10), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data[len(data)-10:], got)
 }
 
-func TestDownloadFileAsBase64(t *testing.T) {
+func TestOpen(t *testing.T) {
 	ctx := context.Background()
-	s3 := getS3(ctx)
+	s3Client := getS3(t)
+	info, data := uploadSample(t, s3Client)
 
-	b64, err := s3.DownloadAsBase64(ctx, preuploadedFileInfo.FileID)
+	r, err := s3Client.Open(ctx, info.FileID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer r.Close()
+	assert.Equal(t, int64(len(data)), r.Size())
 
-	fileBytes, err := base64.StdEncoding.Strict().DecodeString(b64)
+	p := make([]byte, 16)
+	if _, err := r.ReadAt(p, 32); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data[32:48], p)
+
+	if _, err := r.Seek(-16, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
 	if err != nil {
 		t.Fatal(err)
 	}
+	assert.Equal(t, data[len(data)-16:], got)
 
-	assert.Equal(t, preuploadedFileSha256, fmt.Sprintf("%x", sha256.Sum256(fileBytes)))
+	_, err = s3Client.Open(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
 }
 
 func TestPublicLink(t *testing.T) {
 	ctx := context.Background()
+	s3Client := getS3(t)
+	info, data := uploadSample(t, s3Client)
 
-	link, err := getS3(ctx).PublicLink(ctx, preuploadedFileInfo.FileID, s3.DefaultPublicLinkExpiration)
+	link, err := s3Client.PublicLink(ctx, info.FileID, s3.DefaultPublicLinkExpiration)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	t.Log(link)
-	assert.NotEmpty(t, link)
 	assert.NotEmpty(t, link.ExpiredAt)
+
+	resp, err := http.Get(link.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, sha256Hex(data), sha256Hex(body))
+	assert.Equal(t, `attachment; filename=sample.jpg`, resp.Header.Get("Content-Disposition"))
+}
+
+func TestUploadWithOptions(t *testing.T) {
+	ctx := context.Background()
+	s3Client := getS3(t)
+
+	info, err := s3Client.UploadWithOptions(ctx, bytes.NewReader(sampleImage(t)), "photo", ".jpg", storage.UploadOptions{
+		ContentType:        "image/x-custom",
+		CacheControl:       "public, max-age=3600",
+		ContentDisposition: "inline",
+		Metadata:           map[string]string{"Owner": "Dürer", "source": "camera"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "image/x-custom", info.FileMimetype)
+	assert.Equal(t, map[string]string{"owner": "Dürer", "source": "camera"}, info.Metadata)
+
+	got, err := s3Client.FileInfo(ctx, info.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "photo", got.FileName)
+	assert.Equal(t, "image/x-custom", got.FileMimetype)
+	assert.Equal(t, map[string]string{"owner": "Dürer", "source": "camera"}, got.Metadata)
+
+	link, err := s3Client.PublicLink(ctx, info.FileID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(link.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, "inline", resp.Header.Get("Content-Disposition"))
 }
 
 func TestDeleteFile(t *testing.T) {
 	ctx := context.Background()
-	s3 := getS3(ctx)
+	s3Client := getS3(t)
+	info, _ := uploadSample(t, s3Client)
+
+	if err := s3Client.Delete(ctx, info.FileID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s3Client.FileInfo(ctx, info.FileID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestPresignUpload(t *testing.T) {
+	ctx := context.Background()
+	s3Client := getS3(t)
+
+	upload, err := s3Client.PresignUpload(ctx, s3.PresignUploadOptions{
+		Name:        "photo",
+		Ext:         ".jpg",
+		ContentType: "image/jpeg",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, upload.FileID)
+	assert.Equal(t, http.MethodPut, upload.Method)
+
+	data := sampleImage(t)
+	req, err := http.NewRequest(upload.Method, upload.Url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range upload.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	info, err := s3Client.FileInfo(ctx, upload.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "photo", info.FileName)
+	assert.Equal(t, ".jpg", info.FileExt)
+	assert.Equal(t, "image/jpeg", info.FileMimetype)
+	assert.Equal(t, len(data), info.FileSize)
+}
+
+func TestPresignUploadPost(t *testing.T) {
+	ctx := context.Background()
+	s3Client := getS3(t)
+
+	upload, err := s3Client.PresignUploadPost(ctx, s3.PresignUploadOptions{
+		Name:        "photo",
+		Ext:         ".jpg",
+		ContentType: "image/",
+		MaxSize:     1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.MethodPost, upload.Method)
+	assert.Equal(t, upload.FileID, upload.Fields["key"])
+	assert.NotEmpty(t, upload.Fields["policy"])
+	assert.NotEmpty(t, upload.Fields["X-Amz-Signature"])
+
+	policy, err := base64.StdEncoding.DecodeString(upload.Fields["policy"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(policy), `["content-length-range",0,1048576]`)
+	assert.Contains(t, string(policy), `["starts-with","$Content-Type","image/"]`)
+
+	// Fields go before the file, which must be the last field.
+	data := sampleImage(t)
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	for key, value := range upload.Fields {
+		if err := w.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteField("Content-Type", "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	part, err := w.CreateFormFile("file", "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(upload.Url, w.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Less(t, resp.StatusCode, 300)
+
+	info, err := s3Client.FileInfo(ctx, upload.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "photo", info.FileName)
+	assert.Equal(t, ".jpg", info.FileExt)
+	assert.Equal(t, len(data), info.FileSize)
+}
+
+func TestPublicLinkUsesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	s3Client, err := s3.NewS3(ctx, s3.Config{
+		Region:          "us-east-1",
+		Bucket:          "files",
+		AccessKeyId:     "minioadmin",
+		SecretAccessKey: "minioadmin",
+		SessionToken:    "session",
+		Endpoint:        "http://localhost:9000",
+		UsePathStyle:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Presigning is done locally, no request is sent.
+	link, err := s3Client.PublicLink(ctx, "file", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "localhost:9000", u.Host)
+	assert.Equal(t, "/files/file", u.Path)
+	assert.Equal(t, "session", u.Query().Get("X-Amz-Security-Token"))
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	s3Client := getS3(t)
 
-	file, err := downloadSampleFile(ctx)
+	var uploaded []string
+	for i := 0; i < 3; i++ {
+		info, _ := uploadSample(t, s3Client)
+		uploaded = append(uploaded, info.FileID)
+	}
+
+	first, err := s3Client.List(ctx, storage.ListOptions{PageSize: 2})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer cleanupFile(t, file)
+	assert.Len(t, first.Files, 2)
+	assert.NotEmpty(t, first.NextPageToken)
 
-	fileName := file.Name()
-	fileExt := path.Ext(fileName)
-	fileName = strings.TrimSuffix(fileName, fileExt)
-	info, err := s3.Upload(ctx, file, fileName, fileExt)
+	second, err := s3Client.List(ctx, storage.ListOptions{PageSize: 2, PageToken: first.NextPageToken})
 	if err != nil {
 		t.Fatal(err)
 	}
+	assert.Len(t, second.Files, 1)
+	assert.Empty(t, second.NextPageToken)
 
-	if err := s3.Delete(ctx, info.FileID); err != nil {
+	var listed []string
+	for _, file := range append(first.Files, second.Files...) {
+		listed = append(listed, file.FileID)
+	}
+	assert.ElementsMatch(t, uploaded, listed)
+
+	filtered, err := s3Client.List(ctx, storage.ListOptions{Prefix: uploaded[0]})
+	if err != nil {
 		t.Fatal(err)
 	}
+	if assert.Len(t, filtered.Files, 1) {
+		assert.Equal(t, uploaded[0], filtered.Files[0].FileID)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	s3Client := getS3(t)
+
+	assert.Equal(t, "my_file", s3Client.SanitizeFileName("my file"))
+	assert.Equal(t, "reports_2024_q1", s3Client.SanitizeFileName("reports/2024/q1"))
+
+	_, err := s3Client.CleanFileName("reports/2024/q1")
+	assert.ErrorIs(t, err, storage.ErrInvalidFileName)
+}
+
+func TestServeFileRequestsRanges(t *testing.T) {
+	// Record the Range header of every download.
+	var ranges []string
+	fake := newFakeS3Handler(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	s3Client := getS3WithEndpoint(t, server.URL)
+	info, data := uploadSample(t, s3Client)
+	ranges = nil
+
+	req := httptest.NewRequest(http.MethodGet, "/"+info.FileID, nil)
+	req.Header.Set("Range", "bytes=10-13")
+	rec := httptest.NewRecorder()
+	storage.ServeFile(rec, req, s3Client.Storage(), info.FileID)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, data[10:14], rec.Body.Bytes())
+	assert.Equal(t, []string{"bytes=10-13"}, ranges)
+}
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return getS3(t).Storage()
+	})
 }
@@ -0,0 +1,76 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+)
+
+// Storage returns s as a backend-agnostic storage.Storage, which also
+// implements storage.RangeReader and storage.Lister.
+func (s *S3) Storage() storage.Storage {
+	return storageAdapter{s: s}
+}
+
+var (
+	_ storage.RangeReader = storageAdapter{}
+	_ storage.Lister      = storageAdapter{}
+)
+
+type storageAdapter struct {
+	s *S3
+}
+
+func (a storageAdapter) Upload(ctx context.Context, file io.Reader, name, ext string) (storage.FileInfo, error) {
+	info, err := a.s.Upload(ctx, file, name, ext)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+
+	return info.toStorage(), nil
+}
+
+func (a storageAdapter) Stream(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	return a.s.Stream(ctx, fileId)
+}
+
+func (a storageAdapter) StreamRange(ctx context.Context, fileId string, offset, length int64) (io.ReadCloser, error) {
+	return a.s.StreamRange(ctx, fileId, offset, length)
+}
+
+func (a storageAdapter) FileInfo(ctx context.Context, fileId string) (storage.FileInfo, error) {
+	info, err := a.s.FileInfo(ctx, fileId)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+
+	return info.toStorage(), nil
+}
+
+func (a storageAdapter) PublicLink(
+	ctx context.Context,
+	fileId string,
+	expiration time.Duration,
+) (storage.PublicLinkResponse, error) {
+	link, err := a.s.PublicLink(ctx, fileId, expiration)
+	if err != nil {
+		return storage.PublicLinkResponse{}, err
+	}
+
+	return storage.PublicLinkResponse(link), nil
+}
+
+// List implements storage.Lister.
+func (a storageAdapter) List(ctx context.Context, opts storage.ListOptions) (storage.ListResult, error) {
+	return a.s.List(ctx, opts)
+}
+
+func (a storageAdapter) Delete(ctx context.Context, fileId string) error {
+	return a.s.Delete(ctx, fileId)
+}
+
+func (f FileInfo) toStorage() storage.FileInfo {
+	return storage.FileInfo(f)
+}
@@ -1,26 +1,74 @@
 package s3
 
 import (
-	"bytes"
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
-)
+	"strings"
 
-func (s *S3) detectMimeType(file io.Reader) (string, error) {
-	// Create a buffer to store the header of the file in
-	fileHeader := make([]byte, 512)
+	storage "github.com/dptsi/go-storage"
+)
 
-	// Copy the headers into the FileHeader buffer
-	if _, err := file.Read(fileHeader); err != nil {
+func (s *S3) detectMimeType(file *bufio.Reader) (string, error) {
+	// Peek at the header of the file without consuming it. Files smaller
+	// than 512 bytes return io.EOF, which isn't an error here.
+	fileHeader, err := file.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
 		return "", fmt.Errorf("failed to read file header: %w", err)
 	}
 
 	return http.DetectContentType(fileHeader), nil
 }
 
-func (*S3) getSize(stream io.Reader) int {
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(stream)
-	return buf.Len()
+// objectMetadata returns storage.ObjectMetadata as S3 user metadata. S3
+// lowercases the keys, so FileInfo reads them back as they are here. Values
+// are sent as headers, so values which aren't ASCII are encoded as RFC 2047
+// words.
+func objectMetadata(name, ext string, custom map[string]string) map[string]string {
+	lower := make(map[string]string, len(custom))
+	for key, value := range custom {
+		lower[strings.ToLower(key)] = value
+	}
+	metadata := storage.ObjectMetadata(name, ext, lower)
+	for key, value := range metadata {
+		metadata[key] = mime.QEncoding.Encode("utf-8", value)
+	}
+
+	return metadata
+}
+
+// customMetadata returns storage.CustomMetadata of the user metadata of an
+// object, decoded.
+func customMetadata(metadata map[string]string) map[string]string {
+	custom := storage.CustomMetadata(metadata)
+	decoder := new(mime.WordDecoder)
+	for key, value := range custom {
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			custom[key] = decoded
+		}
+	}
+
+	return custom
+}
+
+// metadataFileName decodes the name stored by objectMetadata.
+func metadataFileName(metadata map[string]string) string {
+	name, err := new(mime.WordDecoder).DecodeHeader(metadata[storage.MetadataName])
+	if err != nil {
+		return metadata[storage.MetadataName]
+	}
+
+	return name
+}
+
+// optionalString returns nil for an empty s, so the header isn't sent.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
 }
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Handler serves the file whose ID is the request path, without the leading
+// slash, with ServeFile. Mount it with http.StripPrefix, e.g.
+//
+//	mux.Handle("/files/", http.StripPrefix("/files/", storage.Handler(backend)))
+func Handler(backend Storage) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeFile(w, r, backend, strings.TrimPrefix(r.URL.Path, "/"))
+	})
+}
+
+// ServeFile replies to a GET or HEAD request with the file fileId of
+// backend. Content-Type, ETag, Last-Modified and Content-Disposition are
+// set from the FileInfo of the file, and conditional and range requests are
+// handled like http.ServeContent does. Backends implementing RangeReader
+// only download the requested ranges.
+func ServeFile(w http.ResponseWriter, r *http.Request, backend Storage, fileId string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	info, err := backend.FileInfo(ctx, fileId)
+	if err != nil {
+		status := errorStatus(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	var content io.ReadSeekCloser
+	if rr, ok := backend.(RangeReader); ok {
+		content = NewObjectReader(ctx, rr, fileId, int64(info.FileSize))
+	} else {
+		content = &streamSeeker{ctx: ctx, backend: backend, fileId: fileId, size: int64(info.FileSize)}
+	}
+	defer content.Close()
+
+	header := w.Header()
+	if info.FileMimetype != "" {
+		header.Set("Content-Type", info.FileMimetype)
+	}
+	if info.ETag != "" {
+		header.Set("ETag", quoteETag(info.ETag))
+	}
+	if name := info.FileName + info.FileExt; name != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+	}
+	modTime, _ := time.Parse(time.RFC3339, info.Timestamp)
+	http.ServeContent(w, r, info.FileName+info.FileExt, modTime, content)
+}
+
+// errorStatus returns the HTTP status code of an error returned by a
+// backend.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	}
+
+	return http.StatusInternalServerError
+}
+
+// quoteETag returns etag as a quoted entity tag, which S3 and GCS already
+// return but the ITS Storage API doesn't.
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+
+	return `"` + etag + `"`
+}
+
+// streamSeeker is the io.ReadSeeker of backends which can only stream the
+// whole file. Seeking forward discards the skipped bytes, seeking backward
+// opens the file again.
+type streamSeeker struct {
+	ctx     context.Context
+	backend Storage
+	fileId  string
+	size    int64
+
+	// offset is the position to read from and pos the position of body.
+	offset int64
+	pos    int64
+	body   io.ReadCloser
+}
+
+func (s *streamSeeker) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+	if s.body != nil && s.pos > s.offset {
+		if err := s.Close(); err != nil {
+			return 0, err
+		}
+	}
+	if s.body == nil {
+		body, err := s.backend.Stream(s.ctx, s.fileId)
+		if err != nil {
+			return 0, err
+		}
+		s.body = body
+		s.pos = 0
+	}
+	if s.pos < s.offset {
+		n, err := io.CopyN(io.Discard, s.body, s.offset-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, fmt.Errorf("failed to skip to offset: %w", err)
+		}
+	}
+
+	n, err := s.body.Read(p)
+	s.offset += int64(n)
+	s.pos += int64(n)
+
+	return n, err
+}
+
+func (s *streamSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	s.offset = offset
+
+	return offset, nil
+}
+
+func (s *streamSeeker) Close() error {
+	if s.body == nil {
+		return nil
+	}
+	err := s.body.Close()
+	s.body = nil
+
+	return err
+}
@@ -0,0 +1,118 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/dptsi/go-storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+var serveContent = []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+// streamOnly hides StreamRange, like backends which can only stream whole
+// files.
+type streamOnly struct {
+	storage.Storage
+}
+
+func serve(t *testing.T, backend storage.Storage, method string, header http.Header) *http.Response {
+	info, err := backend.Upload(context.Background(), bytes.NewReader(serveContent), "report", ".txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, "/files/"+info.FileID, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	http.StripPrefix("/files/", storage.Handler(backend)).ServeHTTP(rec, req)
+
+	return rec.Result()
+}
+
+func TestServeFile(t *testing.T) {
+	for name, backend := range map[string]storage.Storage{
+		"range":  memory.NewMemory(memory.Config{}),
+		"stream": streamOnly{memory.NewMemory(memory.Config{})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := serve(t, backend, http.MethodGet, nil)
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, serveContent, body)
+			assert.Equal(t, "36", resp.Header.Get("Content-Length"))
+			assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+			assert.NotEmpty(t, resp.Header.Get("ETag"))
+			assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+			assert.Equal(t, `inline; filename=report.txt`, resp.Header.Get("Content-Disposition"))
+
+			resp = serve(t, backend, http.MethodGet, http.Header{"Range": {"bytes=10-13"}})
+			body, _ = io.ReadAll(resp.Body)
+			assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+			assert.Equal(t, "abcd", string(body))
+			assert.Equal(t, "bytes 10-13/36", resp.Header.Get("Content-Range"))
+
+			resp = serve(t, backend, http.MethodGet, http.Header{"Range": {"bytes=30-31,2-3"}})
+			assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+			mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "multipart/byteranges", mediaType)
+			var parts []string
+			mr := multipart.NewReader(resp.Body, params["boundary"])
+			for {
+				part, err := mr.NextPart()
+				if err != nil {
+					break
+				}
+				b, _ := io.ReadAll(part)
+				parts = append(parts, string(b))
+			}
+			assert.Equal(t, []string{"uv", "23"}, parts)
+		})
+	}
+}
+
+func TestServeFileConditional(t *testing.T) {
+	backend := memory.NewMemory(memory.Config{})
+
+	resp := serve(t, backend, http.MethodGet, nil)
+	etag := resp.Header.Get("ETag")
+
+	resp = serve(t, backend, http.MethodGet, http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp = serve(t, backend, http.MethodGet, http.Header{
+		"If-Modified-Since": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)},
+	})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp = serve(t, backend, http.MethodGet, http.Header{"If-None-Match": {`"other"`}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServeFileErrors(t *testing.T) {
+	backend := memory.NewMemory(memory.Config{})
+
+	resp := serve(t, backend, http.MethodHead, nil)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, body)
+
+	resp = serve(t, backend, http.MethodPost, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	rec := httptest.NewRecorder()
+	storage.ServeFile(rec, httptest.NewRequest(http.MethodGet, "/", nil), backend, "missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const DefaultPublicLinkExpiration = 30 * time.Minute
+
+// Storage is the set of operations every backend supports, so services can
+// swap between ITS Storage API, S3 and GCS by configuration only.
+type Storage interface {
+	// Upload stores the content of file under a newly generated file ID.
+	// ext is the file extension, including the leading dot.
+	Upload(ctx context.Context, file io.Reader, name, ext string) (FileInfo, error)
+
+	// Stream opens the content of the file for reading. The caller must
+	// close the returned reader.
+	Stream(ctx context.Context, fileId string) (io.ReadCloser, error)
+
+	// FileInfo returns the metadata of the file without its content.
+	FileInfo(ctx context.Context, fileId string) (FileInfo, error)
+
+	// PublicLink returns a link which can be used to access the file
+	// without credentials. Backends which can't control the expiration
+	// return an empty ExpiredAt.
+	PublicLink(ctx context.Context, fileId string, expiration time.Duration) (PublicLinkResponse, error)
+
+	// Delete removes the file. Deleting a file which doesn't exist
+	// succeeds, like on S3, so deletes can be retried.
+	Delete(ctx context.Context, fileId string) error
+}
@@ -0,0 +1,238 @@
+// Package storagetest provides a conformance test suite which every
+// storage.Storage implementation is expected to pass.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	sampleName     = "sample"
+	sampleExt      = ".pdf"
+	sampleMimetype = "application/pdf"
+)
+
+// Factory returns the backend under test. It is called once per subtest,
+// use t.Cleanup to release resources.
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs the conformance suite against the backends returned by
+// newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"Upload", testUpload},
+		{"RoundTrip", testRoundTrip},
+		{"FileInfo", testFileInfo},
+		{"PublicLink", testPublicLink},
+		{"Delete", testDelete},
+		{"NotFound", testNotFound},
+		{"List", testList},
+		{"StreamRange", testStreamRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+// sampleFile returns a deterministic PDF-like file larger than the 512
+// bytes used for mime type detection.
+func sampleFile() []byte {
+	data := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(data)
+	return append([]byte("%PDF-1.4\n"), data...)
+}
+
+func upload(t *testing.T, s storage.Storage, data []byte) storage.FileInfo {
+	info, err := s.Upload(context.Background(), bytes.NewReader(data), sampleName, sampleExt)
+	if err != nil {
+		t.Fatalf("failed to upload file: %v", err)
+	}
+	return info
+}
+
+func sha256Hex(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+func testUpload(t *testing.T, s storage.Storage) {
+	data := sampleFile()
+	info := upload(t, s, data)
+
+	assert.NotEmpty(t, info.FileID)
+	assert.Equal(t, sampleExt, info.FileExt)
+	assert.Equal(t, sampleMimetype, info.FileMimetype)
+	assert.Equal(t, len(data), info.FileSize)
+	_, err := time.Parse(time.RFC3339, info.Timestamp)
+	assert.NoError(t, err, "timestamp should be RFC 3339")
+}
+
+func testRoundTrip(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	data := sampleFile()
+	info := upload(t, s, data)
+
+	r, err := s.Stream(ctx, info.FileID)
+	if err != nil {
+		t.Fatalf("failed to stream file: %v", err)
+	}
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	assert.Equal(t, sha256Hex(data), sha256Hex(got))
+}
+
+func testFileInfo(t *testing.T, s storage.Storage) {
+	data := sampleFile()
+	uploaded := upload(t, s, data)
+
+	info, err := s.FileInfo(context.Background(), uploaded.FileID)
+	if err != nil {
+		t.Fatalf("failed to get file info: %v", err)
+	}
+
+	assert.Equal(t, uploaded.FileID, info.FileID)
+	assert.Equal(t, sampleExt, info.FileExt)
+	assert.Equal(t, sampleMimetype, info.FileMimetype)
+	assert.Equal(t, len(data), info.FileSize)
+	assert.Equal(t, uploaded.ETag, info.ETag)
+	_, err = time.Parse(time.RFC3339, info.Timestamp)
+	assert.NoError(t, err, "timestamp should be RFC 3339")
+}
+
+func testPublicLink(t *testing.T, s storage.Storage) {
+	info := upload(t, s, sampleFile())
+
+	link, err := s.PublicLink(context.Background(), info.FileID, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to get public link: %v", err)
+	}
+
+	assert.NotEmpty(t, link.Url)
+	// Backends which don't control the expiration leave ExpiredAt empty.
+	if link.ExpiredAt != "" {
+		expiredAt, err := time.Parse(time.RFC3339, link.ExpiredAt)
+		assert.NoError(t, err, "expired_at should be RFC 3339")
+		assert.True(t, expiredAt.After(time.Now()), "expired_at should be in the future")
+	}
+}
+
+func testDelete(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	info := upload(t, s, sampleFile())
+
+	if err := s.Delete(ctx, info.FileID); err != nil {
+		t.Fatalf("failed to delete file: %v", err)
+	}
+
+	_, err := s.FileInfo(ctx, info.FileID)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "file info of deleted file")
+	_, err = s.Stream(ctx, info.FileID)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "stream of deleted file")
+
+	// Deleting is idempotent.
+	assert.NoError(t, s.Delete(ctx, info.FileID), "delete of deleted file")
+}
+
+func testNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	fileId := uuid.NewString()
+
+	_, err := s.FileInfo(ctx, fileId)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "file info of missing file")
+	_, err = s.Stream(ctx, fileId)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "stream of missing file")
+	assert.NoError(t, s.Delete(ctx, fileId), "delete of missing file")
+}
+
+// testList only runs for backends implementing storage.Lister.
+func testList(t *testing.T, s storage.Storage) {
+	lister, ok := s.(storage.Lister)
+	if !ok {
+		t.Skip("backend doesn't implement storage.Lister")
+	}
+
+	data := sampleFile()
+	uploaded := make(map[string]storage.FileInfo)
+	for i := 0; i < 3; i++ {
+		info := upload(t, s, data)
+		uploaded[info.FileID] = info
+	}
+
+	// PageSize is a maximum which emulators don't always respect, so only
+	// the files across all pages are checked.
+	listed := make(map[string]storage.FileInfo)
+	opts := storage.ListOptions{PageSize: 2}
+	for {
+		result, err := lister.List(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("failed to list files: %v", err)
+		}
+		for _, file := range result.Files {
+			listed[file.FileID] = file
+		}
+		if result.NextPageToken == "" {
+			break
+		}
+		opts.PageToken = result.NextPageToken
+	}
+
+	assert.Len(t, listed, len(uploaded))
+	for id, info := range uploaded {
+		if assert.Contains(t, listed, id) {
+			assert.Equal(t, len(data), listed[id].FileSize)
+			assert.Equal(t, info.ETag, listed[id].ETag)
+		}
+	}
+}
+
+func testStreamRange(t *testing.T, s storage.Storage) {
+	rr, ok := s.(storage.RangeReader)
+	if !ok {
+		t.Skip("backend doesn't implement storage.RangeReader")
+	}
+	ctx := context.Background()
+	data := sampleFile()
+	info := upload(t, s, data)
+
+	for _, tt := range []struct {
+		offset, length int64
+		want           []byte
+	}{
+		{10, 4, data[10:14]},
+		{int64(len(data)) - 4, -1, data[len(data)-4:]},
+		{0, 0, []byte{}},
+	} {
+		r, err := rr.StreamRange(ctx, info.FileID, tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("failed to stream range %d+%d: %v", tt.offset, tt.length, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("failed to read range %d+%d: %v", tt.offset, tt.length, err)
+		}
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := rr.StreamRange(ctx, info.FileID, -1, 4)
+	assert.Error(t, err, "negative offset")
+}
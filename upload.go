@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Codes of the validation errors returned by UploadFiles.
+const (
+	CodeNoFile             = "no_file"
+	CodeTooManyFiles       = "too_many_files"
+	CodeTooLarge           = "too_large"
+	CodeExtNotAllowed      = "ext_not_allowed"
+	CodeMimeTypeNotAllowed = "mime_type_not_allowed"
+	CodeInvalidFileName    = "invalid_file_name"
+	CodeInvalidRequest     = "invalid_request"
+	CodeUploadFailed       = "upload_failed"
+)
+
+const defaultMaxUploadedFiles = 1
+
+// UploadPolicy restricts the files accepted by UploadFiles and
+// UploadHandler. The zero value accepts a single file of any size and type.
+type UploadPolicy struct {
+	// FieldName is the form field the files are sent in. Files in any
+	// field are accepted when empty.
+	FieldName string
+
+	// MaxFiles is the maximum number of files per request. Defaults to 1.
+	MaxFiles int
+
+	// MaxFileSize is the maximum size of each file in bytes. There is no
+	// limit when zero.
+	MaxFileSize int64
+
+	// AllowedMimeTypes are the accepted content types, detected from the
+	// content of the file rather than trusting the client. A type ending
+	// with a slash, such as "image/", accepts any type with that prefix.
+	// Any type is accepted when empty.
+	AllowedMimeTypes []string
+
+	// AllowedExts are the accepted extensions, including the leading dot,
+	// compared case-insensitively. Any extension is accepted when empty.
+	AllowedExts []string
+}
+
+// ValidationError is returned by UploadFiles for files which don't satisfy
+// the UploadPolicy.
+type ValidationError struct {
+	Code     string `json:"code"`
+	Field    string `json:"field,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	if e.FileName == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.FileName, e.Message)
+}
+
+// StatusCode returns the HTTP status code UploadHandler responds with.
+func (e *ValidationError) StatusCode() int {
+	switch e.Code {
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeExtNotAllowed, CodeMimeTypeNotAllowed:
+		return http.StatusUnsupportedMediaType
+	}
+
+	return http.StatusBadRequest
+}
+
+// UploadResponse is the body UploadHandler responds with.
+type UploadResponse struct {
+	Files  []FileInfo         `json:"files,omitempty"`
+	Errors []*ValidationError `json:"errors,omitempty"`
+}
+
+// UploadHandler stores the files of multipart/form-data POST requests in
+// backend with UploadFiles. It responds with 201 Created and the FileInfo
+// of every file, or with the ValidationError of the rejected file.
+func UploadHandler(backend Storage, policy UploadPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		files, err := UploadFiles(r, backend, policy)
+		var validationErr *ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			writeUploadResponse(w, validationErr.StatusCode(), UploadResponse{Errors: []*ValidationError{validationErr}})
+		case err != nil:
+			writeUploadResponse(w, errorStatus(err), UploadResponse{Errors: []*ValidationError{{
+				Code:    CodeUploadFailed,
+				Message: http.StatusText(errorStatus(err)),
+			}}})
+		default:
+			writeUploadResponse(w, http.StatusCreated, UploadResponse{Files: files})
+		}
+	})
+}
+
+func writeUploadResponse(w http.ResponseWriter, status int, response UploadResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UploadFiles reads the files of a multipart/form-data request and stores
+// them in backend as they are received, without writing them to disk. How
+// much of a file is held in memory depends on the backend. The S3 backend
+// buffers files up to s3.Config.MultipartThreshold, 64 MiB by default, to
+// learn their size, and uploads larger files in parts, holding 4 parts of
+// 16 MiB at a time. Other form fields are ignored. Files which don't
+// satisfy policy are rejected with a *ValidationError. If any file is
+// rejected or fails to upload, the files already stored are deleted.
+func UploadFiles(r *http.Request, backend Storage, policy UploadPolicy) ([]FileInfo, error) {
+	if policy.MaxFiles <= 0 {
+		policy.MaxFiles = defaultMaxUploadedFiles
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, &ValidationError{Code: CodeInvalidRequest, Message: err.Error()}
+	}
+
+	ctx := r.Context()
+	var files []FileInfo
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			deleteFiles(ctx, backend, files)
+			return nil, &ValidationError{Code: CodeInvalidRequest, Message: err.Error()}
+		}
+		if part.FileName() == "" || (policy.FieldName != "" && part.FormName() != policy.FieldName) {
+			part.Close()
+			continue
+		}
+
+		if len(files) == policy.MaxFiles {
+			part.Close()
+			deleteFiles(ctx, backend, files)
+			return nil, &ValidationError{
+				Code:    CodeTooManyFiles,
+				Field:   part.FormName(),
+				Message: fmt.Sprintf("at most %d files can be uploaded", policy.MaxFiles),
+			}
+		}
+		info, err := uploadPart(ctx, backend, policy, part.FormName(), part.FileName(), part)
+		part.Close()
+		if err != nil {
+			deleteFiles(ctx, backend, files)
+			return nil, err
+		}
+		files = append(files, info)
+	}
+
+	if len(files) == 0 {
+		return nil, &ValidationError{Code: CodeNoFile, Field: policy.FieldName, Message: "no file was uploaded"}
+	}
+
+	return files, nil
+}
+
+func uploadPart(ctx context.Context, backend Storage, policy UploadPolicy, field, fileName string, r io.Reader) (FileInfo, error) {
+	// Browsers may send the full path of the file.
+	fileName = filepath.Base(strings.ReplaceAll(fileName, `\`, "/"))
+	ext := filepath.Ext(fileName)
+	if !policy.allowsExt(ext) {
+		return FileInfo{}, &ValidationError{
+			Code:     CodeExtNotAllowed,
+			Field:    field,
+			FileName: fileName,
+			Message:  fmt.Sprintf("extension %q is not allowed", ext),
+		}
+	}
+
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return FileInfo{}, &ValidationError{Code: CodeInvalidRequest, Field: field, FileName: fileName, Message: err.Error()}
+	}
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(header))
+	if !policy.allowsMimeType(mimeType) {
+		return FileInfo{}, &ValidationError{
+			Code:     CodeMimeTypeNotAllowed,
+			Field:    field,
+			FileName: fileName,
+			Message:  fmt.Sprintf("content type %q is not allowed", mimeType),
+		}
+	}
+
+	content := &limitedReader{r: buffered, remaining: policy.MaxFileSize}
+	if policy.MaxFileSize <= 0 {
+		content.remaining = -1
+	}
+	info, err := backend.Upload(ctx, content, strings.TrimSuffix(fileName, ext), ext)
+	switch {
+	// Backends don't always wrap the error of the reader.
+	case content.exceeded:
+		return FileInfo{}, &ValidationError{
+			Code:     CodeTooLarge,
+			Field:    field,
+			FileName: fileName,
+			Message:  fmt.Sprintf("file is larger than %d bytes", policy.MaxFileSize),
+		}
+	case errors.Is(err, ErrInvalidFileName):
+		return FileInfo{}, &ValidationError{Code: CodeInvalidFileName, Field: field, FileName: fileName, Message: err.Error()}
+	case err != nil:
+		return FileInfo{}, fmt.Errorf("failed to upload %s: %w", fileName, err)
+	}
+
+	return info, nil
+}
+
+func (p UploadPolicy) allowsExt(ext string) bool {
+	if len(p.AllowedExts) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedExts {
+		if strings.EqualFold(ext, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p UploadPolicy) allowsMimeType(mimeType string) bool {
+	if len(p.AllowedMimeTypes) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedMimeTypes {
+		if mimeType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mimeType, allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteFiles removes the files of a rejected request. Errors are ignored,
+// since the request already failed.
+func deleteFiles(ctx context.Context, backend Storage, files []FileInfo) {
+	for _, file := range files {
+		backend.Delete(context.WithoutCancel(ctx), file.FileID)
+	}
+}
+
+var errFileTooLarge = errors.New("file exceeds the maximum size")
+
+// limitedReader fails with errFileTooLarge once more than remaining bytes
+// are read, unlike io.LimitReader which silently truncates. A negative
+// remaining doesn't limit the size.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, errFileTooLarge
+	}
+	if l.remaining < 0 {
+		return l.r.Read(p)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		return int(l.remaining), errFileTooLarge
+	}
+	l.remaining -= int64(n)
+
+	return n, err
+}
@@ -0,0 +1,121 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	storage "github.com/dptsi/go-storage"
+	"github.com/dptsi/go-storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+var samplePDF = append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("sample file content\n"), 16)...)
+
+type formFile struct {
+	field, name string
+	content     []byte
+}
+
+func postFiles(t *testing.T, backend storage.Storage, policy storage.UploadPolicy, files ...formFile) (int, storage.UploadResponse) {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	if err := w.WriteField("description", "ignored"); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		part, err := w.CreateFormFile(file.field, file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write(file.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	storage.UploadHandler(backend, policy).ServeHTTP(rec, req)
+
+	var response storage.UploadResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, response
+}
+
+func TestUploadHandler(t *testing.T) {
+	backend := memory.NewMemory(memory.Config{})
+	policy := storage.UploadPolicy{
+		FieldName:        "file",
+		MaxFiles:         2,
+		MaxFileSize:      1024,
+		AllowedMimeTypes: []string{"application/pdf", "image/"},
+		AllowedExts:      []string{".pdf", ".png"},
+	}
+
+	status, response := postFiles(t, backend, policy,
+		formFile{"file", "report one.PDF", samplePDF},
+		formFile{"other", "ignored.pdf", samplePDF},
+		formFile{"file", `C:\Users\me\report.pdf`, samplePDF},
+	)
+	assert.Equal(t, http.StatusCreated, status)
+	if assert.Len(t, response.Files, 2) {
+		assert.Equal(t, "report one", response.Files[0].FileName)
+		assert.Equal(t, ".PDF", response.Files[0].FileExt)
+		assert.Equal(t, "report", response.Files[1].FileName)
+		_, err := backend.FileInfo(context.Background(), response.Files[1].FileID)
+		assert.NoError(t, err)
+	}
+}
+
+func TestUploadHandlerValidation(t *testing.T) {
+	policy := storage.UploadPolicy{
+		MaxFiles:         1,
+		MaxFileSize:      int64(len(samplePDF)),
+		AllowedMimeTypes: []string{"application/pdf"},
+		AllowedExts:      []string{".pdf"},
+	}
+
+	for name, tt := range map[string]struct {
+		files  []formFile
+		status int
+		code   string
+	}{
+		"no file":   {nil, http.StatusBadRequest, storage.CodeNoFile},
+		"extension": {[]formFile{{"file", "report.exe", samplePDF}}, http.StatusUnsupportedMediaType, storage.CodeExtNotAllowed},
+		"mime type": {[]formFile{{"file", "report.pdf", []byte("plain text")}}, http.StatusUnsupportedMediaType, storage.CodeMimeTypeNotAllowed},
+		"too large": {[]formFile{{"file", "report.pdf", append(samplePDF, '!')}}, http.StatusRequestEntityTooLarge, storage.CodeTooLarge},
+		"too many":  {[]formFile{{"file", "a.pdf", samplePDF}, {"file", "b.pdf", samplePDF}}, http.StatusBadRequest, storage.CodeTooManyFiles},
+	} {
+		t.Run(name, func(t *testing.T) {
+			backend := memory.NewMemory(memory.Config{})
+			status, response := postFiles(t, backend, policy, tt.files...)
+			assert.Equal(t, tt.status, status)
+			if assert.Len(t, response.Errors, 1) {
+				assert.Equal(t, tt.code, response.Errors[0].Code)
+			}
+			assert.Empty(t, response.Files)
+		})
+	}
+}
+
+func TestUploadFilesDeletesUploadedFilesOnError(t *testing.T) {
+	backend := memory.NewMemory(memory.Config{NewID: func() string { return "first" }})
+
+	status, _ := postFiles(t, backend, storage.UploadPolicy{MaxFiles: 2, AllowedExts: []string{".pdf"}},
+		formFile{"file", "a.pdf", samplePDF},
+		formFile{"file", "b.exe", samplePDF},
+	)
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+	_, err := backend.FileInfo(context.Background(), "first")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}